@@ -0,0 +1,254 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+)
+
+// State is the state of a registered picker
+type State struct {
+	// Name, the registered name
+	Name string `json:"name"`
+	// Type, the go type of the picker
+	Type string `json:"type"`
+	// Set, the set info of Set, Priority and Zone
+	Set *loadbalance.SetInfo `json:"set,omitempty"`
+	// Aperture, the snapshot of Aperture
+	Aperture *Aperture `json:"aperture,omitempty"`
+	// Nodes, the stats and set of every item named by the key if the picker is a Statser
+	Nodes []loadbalance.NodeStatsOf[string] `json:"nodes"`
+}
+
+// Aperture is the snapshot of an Aperture with the peers named by the key
+type Aperture struct {
+	loadbalance.ApertureSnapshot
+	// Peers, the remote peers in the aperture
+	Peers []Peer `json:"peers"`
+}
+
+// Peer is a remote peer in the aperture
+type Peer struct {
+	// Index of the peer in the remote peers
+	Index int `json:"index"`
+	// Name, the peer named by the key
+	Name string `json:"name"`
+	// Weight, the ratio of the peer covered by the aperture in the ring
+	Weight float64 `json:"weight"`
+}
+
+type options struct {
+	locker sync.Locker
+}
+
+// Option configures a registered picker
+type Option func(*options)
+
+// WithLocker sets the lock held while reading the state of the picker,
+// it should be the one held while updating the picker,
+// the picker is read without lock by default
+func WithLocker(locker sync.Locker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// Registry contains the pickers rendered by its Handler
+type Registry struct {
+	states map[string]func() State
+	mu     sync.RWMutex
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		states: make(map[string]func() State),
+	}
+}
+
+// DefaultRegistry is used by Register and Handler
+var DefaultRegistry = NewRegistry()
+
+// Register registers the picker to the DefaultRegistry
+func Register[T any](name string, n loadbalance.NexterOf[T], opts ...Option) {
+	RegisterTo(DefaultRegistry, name, n, opts...)
+}
+
+// RegisterTo registers the picker to the registry,
+// replaces the one with the same name
+func RegisterTo[T any](r *Registry, name string, n loadbalance.NexterOf[T], opts ...Option) {
+	RegisterWithKey(r, name, n, nil, opts...)
+}
+
+// RegisterWithKey registers the picker to the registry,
+// items are named by key, or by their String or pointer address if nil
+func RegisterWithKey[T any](r *Registry, name string, n loadbalance.NexterOf[T], key loadbalance.KeyFunc[T], opts ...Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if key == nil {
+		key = func(item T) string {
+			return internal.Name(item)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.states[name] = func() State {
+		if o.locker != nil {
+			o.locker.Lock()
+			defer o.locker.Unlock()
+		}
+
+		state := State{
+			Name:  name,
+			Type:  fmt.Sprintf("%T", n),
+			Nodes: make([]loadbalance.NodeStatsOf[string], 0),
+		}
+
+		if s, ok := n.(interface{ Info() loadbalance.SetInfo }); ok {
+			info := s.Info()
+			state.Set = &info
+		}
+
+		if a, ok := n.(loadbalance.Snapshotter); ok {
+			snapshot := a.Snapshot()
+			state.Aperture = &Aperture{
+				ApertureSnapshot: snapshot,
+				Peers:            make([]Peer, 0, len(snapshot.Peers)),
+			}
+			for _, p := range snapshot.Peers {
+				name := internal.Name(p.Peer)
+				if peer, ok := p.Peer.(T); ok {
+					name = key(peer)
+				}
+				state.Aperture.Peers = append(state.Aperture.Peers, Peer{Index: p.Index, Name: name, Weight: p.Weight})
+			}
+		}
+
+		for _, s := range loadbalance.Stats(n) {
+			state.Nodes = append(state.Nodes, loadbalance.NodeStatsOf[string]{
+				Item:            key(s.Item),
+				Weight:          s.Weight,
+				EffectiveWeight: s.EffectiveWeight,
+				CurrentWeight:   s.CurrentWeight,
+				Inflight:        s.Inflight,
+				EWMA:            s.EWMA,
+				LastSample:      s.LastSample,
+				Ejected:         s.Ejected,
+				Set:             s.Set,
+			})
+		}
+
+		return state
+	}
+}
+
+// Unregister removes the picker from the registry
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.states, name)
+}
+
+// States returns the current state of every picker ordered by name
+func (r *Registry) States() []State {
+	r.mu.RLock()
+	funcs := make([]func() State, 0, len(r.states))
+	for _, state := range r.states {
+		funcs = append(funcs, state)
+	}
+	r.mu.RUnlock()
+
+	// pickers are locked by their own lockers out of the registry lock
+	states := make([]State, 0, len(funcs))
+	for _, state := range funcs {
+		states = append(states, state())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+
+	return states
+}
+
+// Handler returns the Handler of the DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// Handler returns a http.Handler renders the states of all pickers,
+// as JSON if `?format=json` or JSON is accepted, otherwise as HTML
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		states := r.States()
+
+		// rendered to a buffer first, so errors never follow a partial body
+		var buf bytes.Buffer
+		contentType := "text/html; charset=utf-8"
+		var err error
+		if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+			contentType = "application/json"
+			enc := json.NewEncoder(&buf)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(states)
+		} else {
+			err = page.Execute(&buf, states)
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		buf.WriteTo(w)
+	})
+}
+
+var page = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>loadbalance</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.ejected { color: #c00; }
+</style>
+</head>
+<body>
+<h1>loadbalance</h1>
+{{range .}}
+<h2>{{.Name}} <small>{{.Type}}</small></h2>
+{{with .Set}}<p>set: {{.Name}} region: {{.Region}} zone: {{.Zone}} unit: {{.UnitName}}{{with .Labels}} labels: {{.}}{{end}}</p>{{end}}
+{{with .Aperture}}
+<p>aperture: local {{.LocalIndex}}/{{.LocalCount}}, remote {{.RemoteCount}}, logical {{.LogicalAperture}}, effective {{.EffectiveAperture}}, offset {{printf "%.4f" .Offset}}, width {{printf "%.4f" .Width}}</p>
+<table>
+<tr><th>peer</th><th>index</th><th>weight</th></tr>
+{{range .Peers}}<tr><td>{{.Name}}</td><td>{{.Index}}</td><td>{{printf "%.4f" .Weight}}</td></tr>
+{{end}}</table>
+{{end}}
+<table>
+<tr><th>item</th><th>weight</th><th>effective weight</th><th>current weight</th><th>inflight</th><th>latency ewma</th><th>last sample</th><th>ejected</th><th>set</th></tr>
+{{range .Nodes}}<tr{{if .Ejected}} class="ejected"{{end}}><td>{{.Item}}</td><td>{{.Weight}}</td><td>{{.EffectiveWeight}}</td><td>{{.CurrentWeight}}</td><td>{{.Inflight}}</td><td>{{.EWMA}}</td><td>{{with .LastSample}}{{.Format "15:04:05.000"}}{{end}}</td><td>{{.Ejected}}</td><td>{{with .Set}}{{.Name}} {{.Region}} {{.Zone}} {{.UnitName}}{{end}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>no picker registered</p>
+{{end}}
+</body>
+</html>
+`))
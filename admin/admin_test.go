@@ -0,0 +1,193 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/admin"
+	"github.com/hnlq715/go-loadbalance/aperture"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/priority"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/set"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	r := admin.NewRegistry()
+
+	info := loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+	p := priority.NewOf[string](info)
+	p.Add("10.0.0.1:80", 1, info)
+	p.Add("<10.0.0.2:80>", 2, info)
+	p.SetHealthy("<10.0.0.2:80>", false)
+	admin.RegisterTo[string](r, "priority", p)
+
+	a := aperture.New(roundrobin.NewSmoothRoundrobin, aperture.WithLogicalAperture(1))
+	a.Update("1", []string{"0", "1"}, []interface{}{"10.0.1.1:80", "10.0.1.2:80"})
+	admin.RegisterTo[interface{}](r, "aperture", a)
+
+	admin.RegisterTo[interface{}](r, "gone", roundrobin.NewSmoothRoundrobin())
+	r.Unregister("gone")
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var states []admin.State
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&states))
+		assert.Len(t, states, 2)
+
+		assert.Equal(t, "aperture", states[0].Name)
+		assert.Nil(t, states[0].Set)
+		assert.Equal(t, 1, states[0].Aperture.EffectiveAperture)
+		assert.Equal(t, "10.0.1.2:80", states[0].Aperture.Peers[0].Name)
+		assert.Equal(t, []loadbalance.NodeStatsOf[string]{
+			{Item: "10.0.1.2:80", Weight: 1, EffectiveWeight: 1},
+		}, states[0].Nodes)
+
+		assert.Equal(t, "priority", states[1].Name)
+		assert.Equal(t, &info, states[1].Set)
+		assert.Nil(t, states[1].Aperture)
+		assert.Equal(t, []loadbalance.NodeStatsOf[string]{
+			{Item: "10.0.0.1:80", Weight: 1, EffectiveWeight: 1, Set: &info},
+			{Item: "<10.0.0.2:80>", Weight: 2, Ejected: true, Set: &info},
+		}, states[1].Nodes)
+	})
+
+	t.Run("html", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+		body, _ := io.ReadAll(w.Body)
+		assert.Contains(t, string(body), "<h2>priority")
+		assert.Contains(t, string(body), "set: app region: bj")
+		assert.Contains(t, string(body), "<td>10.0.1.2:80</td>")
+		assert.Contains(t, string(body), `<tr class="ejected"><td>&lt;10.0.0.2:80&gt;</td>`)
+		assert.NotContains(t, string(body), "gone")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		admin.NewRegistry().Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Contains(t, w.Body.String(), "no picker registered")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		r := admin.NewRegistry()
+		rr := roundrobin.NewSmoothRoundrobin()
+		p := p2c.NewLeastLoaded()
+		p.Add("10.0.0.1:80", math.NaN())
+		admin.RegisterTo[interface{}](r, "rr", rr)
+		admin.RegisterTo[interface{}](r, "nan", p)
+
+		// nothing is written before the error
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+		assert.Equal(t, 500, w.Code)
+		assert.NotContains(t, w.Body.String(), "rr")
+	})
+}
+
+func TestRegisterWithKey(t *testing.T) {
+	type conn struct {
+		addr string
+	}
+
+	r := admin.NewRegistry()
+	p := p2c.NewPeakEwmaOf[*conn]()
+	c := &conn{addr: "10.0.0.1:80"}
+	p.Add(c, 1)
+	admin.RegisterWithKey[*conn](r, "keyed", p, func(c *conn) string { return c.addr })
+	admin.RegisterTo[*conn](r, "pointer", p)
+
+	states := r.States()
+	assert.Equal(t, []loadbalance.NodeStatsOf[string]{{Item: "10.0.0.1:80", Weight: 1}}, states[0].Nodes)
+	assert.Equal(t, fmt.Sprintf("%p", c), states[1].Nodes[0].Item)
+
+	// no sample yet
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+	assert.NotContains(t, w.Body.String(), "last_sample")
+}
+
+func TestAperturePeers(t *testing.T) {
+	type conn struct {
+		addr string
+	}
+
+	r := admin.NewRegistry()
+	a := aperture.NewOf[*conn](nil, aperture.WithLogicalAperture(1))
+	c := &conn{addr: "10.0.0.1:80"}
+	a.Update("0", []string{"0"}, []*conn{c})
+	admin.RegisterWithKey[*conn](r, "keyed", a, func(c *conn) string { return c.addr })
+	admin.RegisterTo[*conn](r, "pointer", a)
+
+	// peers are named like the nodes
+	states := r.States()
+	assert.Equal(t, []admin.Peer{{Index: 0, Name: "10.0.0.1:80", Weight: 1}}, states[0].Aperture.Peers)
+	assert.Equal(t, "10.0.0.1:80", states[0].Nodes[0].Item)
+	assert.Equal(t, fmt.Sprintf("%p", c), states[1].Aperture.Peers[0].Name)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, w.Body.String(), "<td>10.0.0.1:80</td><td>0</td>")
+	assert.NotContains(t, w.Body.String(), "&amp;{")
+}
+
+func TestRouter(t *testing.T) {
+	r := admin.NewRegistry()
+
+	unit01 := loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+	unit02 := loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "02"}
+	router := set.NewRouterOf[string](nil, set.WithDefault(unit01))
+	router.Add("10.0.0.1:80", 1, unit01)
+	router.Add("10.0.0.2:80", 1, unit02)
+	admin.RegisterTo[string](r, "router", router)
+
+	// the set assignment of every item
+	assert.Equal(t, []loadbalance.NodeStatsOf[string]{
+		{Item: "10.0.0.1:80", Weight: 1, Set: &unit01},
+		{Item: "10.0.0.2:80", Weight: 1, Set: &unit02},
+	}, r.States()[0].Nodes)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, w.Body.String(), "<td>app bj  02</td>")
+}
+
+func TestWithLocker(t *testing.T) {
+	r := admin.NewRegistry()
+
+	var mu sync.Mutex
+	info := loadbalance.SetInfo{Name: "app"}
+	p := priority.NewOf[string](info)
+	admin.RegisterTo[string](r, "priority", p, admin.WithLocker(&mu))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			p.Add(fmt.Sprint(i), 1, info)
+			p.SetHealthy(fmt.Sprint(i/2), false)
+			mu.Unlock()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		r.States()
+	}
+	wg.Wait()
+
+	assert.Len(t, r.States()[0].Nodes, 100)
+}
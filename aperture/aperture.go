@@ -1,141 +1,608 @@
 package aperture
 
 import (
+	"io"
 	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
 	"github.com/hnlq715/go-loadbalance/p2c"
 	"github.com/hnlq715/go-loadbalance/roundrobin"
 	"google.golang.org/grpc/balancer"
 )
 
+// ApertureOf is a loadbalance.ApertureOf implementing all its optional interfaces
+type ApertureOf[T any] interface {
+	loadbalance.ApertureOf[T]
+	loadbalance.ErrNexterOf[T]
+	loadbalance.CandidaterOf[T]
+	loadbalance.DynamicApertureSetter
+	loadbalance.CoordinateSetter
+	loadbalance.ApertureUpdaterOf[T]
+	loadbalance.Snapshotter
+	loadbalance.StatserOf[T]
+}
+
+// Aperture is an ApertureOf items of any type
+type Aperture = ApertureOf[interface{}]
+
+var _ Aperture = (*aperture[interface{}])(nil)
+
 // Aperture support map local peers to remote peers
 // to divide remote peers into subsets
 // to reduce the connections and separate services into small sets
-type aperture struct {
-	localID         string
-	localPeers      []string
-	localPeersMap   map[string]int
-	remotePeers     []interface{}
+type aperture[T any] struct {
+	localID       string
+	localPeers    []string
+	localPeersMap map[string]int
+	remotePeers   []T
+	// logicalAperture is the configured one, which is resized by load
+	// and capped by the number of remote peers on every rebuild
 	logicalAperture int
 
-	picker        loadbalance.Picker
-	apertureIdxes []int
+	// coordinate of the local peer, used instead of local peers if instanceCount > 0
+	instanceID    int
+	instanceCount int
+
+	// random aperture is used if the local peer is unknown,
+	// which starts at randomOffset and widens by load
+	randomOffset float64
+
+	// dynamic aperture resizes the logical aperture by load,
+	// band is the effective one used by Next, a *resizeBand
+	dynamic    loadbalance.DynamicAperture
+	band       atomic.Value
+	resize     int
+	resizing   int32
+	lastResize int64 // unix nano, read without holding mu
+	now        func() time.Time
+	inflight   int64
+	width      int64
+
+	newPicker       loadbalance.PickerFactoryOf[T]
+	picker          atomic.Pointer[pickerHolder[T]]
+	apertureIdxes   []int
+	apertureWeights []float64
+	snapshot        loadbalance.ApertureSnapshot
+	// stale means the picker must be rebuilt even if the aperture is the same
+	stale bool
+
+	mu sync.Mutex
 }
 
 const (
 	// defaultLogicalAperture means the max logic aperture size
 	// to control the stability for aperture load balance algorithm
 	defaultLogicalAperture int = 12
+
+	// randomHighLoad means the random aperture widens
+	// when the inflight requests per remote peer exceeds it
+	randomHighLoad float64 = 2
+
+	// randomLowLoad means the random aperture shrinks back
+	// when the inflight requests per remote peer falls below it
+	randomLowLoad float64 = 0.5
+
+	// randomCooldown is the minimum interval between two resizes of the random aperture
+	randomCooldown = time.Second
 )
 
-// NewLeastLoadedApeture returns an Apeture interface with least loaded p2c
-func NewLeastLoadedApeture() loadbalance.Aperture {
-	return &aperture{
-		logicalAperture: defaultLogicalAperture,
-		localPeers:      make([]string, 0),
-		localPeersMap:   make(map[string]int),
-		remotePeers:     make([]interface{}, 0),
-		picker:          p2c.NewLeastLoaded(),
+var (
+	// processRand is seeded once per process for random aperture
+	processRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	processRandMu sync.Mutex
+)
+
+type options struct {
+	logicalAperture int
+	instanceID      int
+	instanceCount   int
+	dynamic         loadbalance.DynamicAperture
+}
+
+// Option configures the aperture
+type Option func(*options)
+
+// WithLogicalAperture sets the logical aperture
+func WithLogicalAperture(width int) Option {
+	return func(o *options) {
+		if width > 0 {
+			o.logicalAperture = width
+		}
 	}
 }
 
-// NewPeakEwmaAperture returns an Apeture interface with pewma p2c
-func NewPeakEwmaAperture() loadbalance.Aperture {
-	return &aperture{
-		logicalAperture: defaultLogicalAperture,
-		localPeers:      make([]string, 0),
-		localPeersMap:   make(map[string]int),
-		remotePeers:     make([]interface{}, 0),
-		picker:          p2c.NewPeakEwma(),
+// WithCoordinate sets the coordinate of the local peer,
+// an invalid one is ignored, so the local peers are used instead
+func WithCoordinate(instanceID, instanceCount int) Option {
+	return func(o *options) {
+		if instanceCount > 0 && instanceID >= 0 && instanceID < instanceCount {
+			o.instanceID = instanceID
+			o.instanceCount = instanceCount
+		}
 	}
 }
 
-// NewSmoothRoundrobin returns an Apeture interface with smooth roundrobin
-func NewSmoothRoundrobin() loadbalance.Aperture {
-	return &aperture{
-		logicalAperture: defaultLogicalAperture,
+// WithDynamicAperture sets the dynamic aperture
+func WithDynamicAperture(dynamic loadbalance.DynamicAperture) Option {
+	return func(o *options) {
+		o.dynamic = dynamic
+	}
+}
+
+// New returns an Aperture interface with the picker built by factory,
+// a new picker is built every time the aperture changes,
+// so the factory may wrap it with any decorators.
+// The least loaded p2c is used if factory is nil.
+func New(factory loadbalance.PickerFactory, opts ...Option) Aperture {
+	return NewOf(factory, opts...)
+}
+
+// NewOf returns an ApertureOf interface of remote peers of type T,
+// with the picker built by factory like New
+func NewOf[T any](factory loadbalance.PickerFactoryOf[T], opts ...Option) ApertureOf[T] {
+	if factory == nil {
+		factory = p2c.NewLeastLoadedOf[T]
+	}
+
+	o := options{logicalAperture: defaultLogicalAperture}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	processRandMu.Lock()
+	offset := processRand.Float64()
+	processRandMu.Unlock()
+
+	a := &aperture[T]{
+		logicalAperture: o.logicalAperture,
+		instanceID:      o.instanceID,
+		instanceCount:   o.instanceCount,
+		dynamic:         o.dynamic,
 		localPeers:      make([]string, 0),
 		localPeersMap:   make(map[string]int),
-		remotePeers:     make([]interface{}, 0),
-		picker:          roundrobin.NewSmoothRoundrobin(),
+		remotePeers:     make([]T, 0),
+		randomOffset:    offset,
+		now:             time.Now,
+		newPicker:       factory,
 	}
+	a.picker.Store(&pickerHolder[T]{picker: factory()})
+	a.band.Store((*resizeBand)(nil))
+
+	// starts within the bounds of dynamic aperture
+	a.SetDynamicAperture(a.dynamic)
+
+	return a
+}
+
+// NewLeastLoadedApeture returns an Apeture interface with least loaded p2c
+func NewLeastLoadedApeture() Aperture {
+	return New(p2c.NewLeastLoaded)
+}
+
+// NewPeakEwmaAperture returns an Apeture interface with pewma p2c
+func NewPeakEwmaAperture() Aperture {
+	return New(p2c.NewPeakEwma)
+}
+
+// NewSmoothRoundrobin returns an Apeture interface with smooth roundrobin
+func NewSmoothRoundrobin() Aperture {
+	return New(roundrobin.NewSmoothRoundrobin)
+}
+
+// NewDeterministicAperture returns an Apeture interface with least loaded p2c,
+// the local peer is mapped to remote peers by its coordinate,
+// which is the same as finagle's deterministic aperture.
+// The instance id must be in [0, instance count), otherwise the coordinate is ignored
+// and a random aperture is used until the local peers are set.
+func NewDeterministicAperture(instanceID, instanceCount int) Aperture {
+	return New(p2c.NewLeastLoaded, WithCoordinate(instanceID, instanceCount))
 }
 
 // SetLogicalAperture sets the logical aperture size
-func (a *aperture) SetLogicalAperture(width int) {
+func (a *aperture[T]) SetLogicalAperture(width int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if width > 0 {
 		a.logicalAperture = width
+		a.resize = 0
 		a.rebuild()
 	}
 }
 
+// SetDynamicAperture sets the dynamic aperture, the zero value disables it
+func (a *aperture[T]) SetDynamicAperture(dynamic loadbalance.DynamicAperture) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.dynamic = dynamic
+	a.resize = 0
+
+	// starts within the bounds
+	if dynamic.Min > 0 && a.logicalAperture < dynamic.Min {
+		a.resize = dynamic.Min - a.logicalAperture
+	}
+	if dynamic.Max > 0 && a.logicalAperture > dynamic.Max {
+		a.resize = dynamic.Max - a.logicalAperture
+	}
+
+	a.rebuild()
+}
+
 // SetLocalPeerID sets the local peer id
-func (a *aperture) SetLocalPeerID(id string) {
+func (a *aperture[T]) SetLocalPeerID(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.localID = id
 	a.rebuild()
 }
 
+// SetCoordinate sets the coordinate of the local peer,
+// a zero instance count switches back to local peers,
+// an invalid one is ignored and the current one is kept
+func (a *aperture[T]) SetCoordinate(instanceID, instanceCount int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if instanceCount < 0 || instanceID < 0 || (instanceCount > 0 && instanceID >= instanceCount) {
+		return
+	}
+
+	a.instanceID = instanceID
+	a.instanceCount = instanceCount
+	a.rebuild()
+}
+
 // SetLocalPeers sets the local peers
-func (a *aperture) SetLocalPeers(localPeers []string) {
+func (a *aperture[T]) SetLocalPeers(localPeers []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.setLocalPeers(localPeers)
+	a.rebuild()
+}
+
+// setLocalPeers sets the local peers and rebuilds the index of them
+func (a *aperture[T]) setLocalPeers(localPeers []string) {
 	a.localPeers = localPeers
+	a.localPeersMap = make(map[string]int, len(localPeers))
 	for idx, local := range localPeers {
 		a.localPeersMap[local] = idx
 	}
+}
 
+// Update sets the local peer id, local peers and remote peers at once,
+// and rebuilds the aperture only once
+func (a *aperture[T]) Update(localID string, localPeers []string, remotePeers []T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.localID = localID
+	a.setLocalPeers(localPeers)
+	a.remotePeers = remotePeers
+	a.stale = true
 	a.rebuild()
 }
 
 // SetRemotePeers sets the remote peers
-func (a *aperture) SetRemotePeers(remotePeers []interface{}) {
+func (a *aperture[T]) SetRemotePeers(remotePeers []T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.remotePeers = remotePeers
+	a.stale = true
 	a.rebuild()
 }
 
 // Next returns the next selected item
-func (a *aperture) Next() (interface{}, func(balancer.DoneInfo)) {
-	return a.picker.Next()
+func (a *aperture[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := a.NextErr()
+	return item, done
+}
+
+// NextErr returns the next selected item,
+// or ErrApertureNotReady before the first aperture is built
+func (a *aperture[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	item, done, _, err := a.next(false)
+	return item, done, err
+}
+
+// NextCandidates returns the next selected item and the candidates compared by the picker,
+// or ErrApertureNotReady before the first aperture is built
+func (a *aperture[T]) NextCandidates() (T, func(balancer.DoneInfo), []loadbalance.NodeStatsOf[T], error) {
+	return a.next(true)
+}
+
+// next returns the next selected item, and the candidates compared if asked
+func (a *aperture[T]) next(candidates bool) (T, func(balancer.DoneInfo), []loadbalance.NodeStatsOf[T], error) {
+	if atomic.LoadInt64(&a.width) == 0 {
+		var zero T
+		return zero, internal.EmptyDoneFunc, nil, loadbalance.ErrApertureNotReady
+	}
+
+	var (
+		item  T
+		done  func(balancer.DoneInfo)
+		stats []loadbalance.NodeStatsOf[T]
+		err   error
+	)
+	if candidates {
+		item, done, stats, err = loadbalance.NextCandidates[T](a.picker.Load().picker)
+	} else {
+		item, done, err = loadbalance.NextErr[T](a.picker.Load().picker)
+	}
+	if err != nil {
+		return item, done, stats, err
+	}
+
+	inflight := atomic.AddInt64(&a.inflight, 1)
+	a.observe(inflight)
+
+	return item, func(info balancer.DoneInfo) {
+		atomic.AddInt64(&a.inflight, -1)
+		done(info)
+	}, stats, nil
+}
+
+// observe resizes the logical aperture by one remote peer
+// when the inflight requests per remote peer is out of the band
+func (a *aperture[T]) observe(inflight int64) {
+	band := a.band.Load().(*resizeBand)
+	if band == nil {
+		return
+	}
+
+	width := atomic.LoadInt64(&a.width)
+	if width == 0 {
+		return
+	}
+
+	delta := 0
+	load := float64(inflight) / float64(width)
+	if band.HighLoad > 0 && load > band.HighLoad {
+		delta = 1
+	} else if band.LowLoad > 0 && load < band.LowLoad {
+		delta = -1
+	} else {
+		return
+	}
+
+	// checks the bounds and the cooldown before taking the lock, which is shared with updates
+	if size := band.size + delta; size < band.lower || size > band.upper {
+		return
+	}
+	now := a.now()
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&a.lastResize))) < band.Cooldown {
+		return
+	}
+
+	// only one goroutine resizes the aperture at the same time
+	if !atomic.CompareAndSwapInt32(&a.resizing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&a.resizing, 0)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// the aperture may be rebuilt since the band is loaded
+	band = a.band.Load().(*resizeBand)
+	if band == nil {
+		return
+	}
+
+	size := a.effectiveAperture() + delta
+	if size < band.lower || size > band.upper {
+		return
+	}
+
+	a.resize = size - a.logicalAperture
+	atomic.StoreInt64(&a.lastResize, now.UnixNano())
+	a.rebuild()
 }
 
 // List returns the remote peers for the local peer id
 // NOTE: current for test/debug only
-func (a *aperture) List() []int {
+func (a *aperture[T]) List() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	return a.apertureIdxes
 }
 
-// rebuild just rebuilds the aperture when any arguments changed
-func (a *aperture) rebuild() {
-	if len(a.localPeers) == 0 {
-		return
-	}
+// Stats returns the state of every item in the aperture
+func (a *aperture[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	return loadbalance.Stats[T](a.picker.Load().picker)
+}
+
+// Snapshot returns the state of the aperture when it was last built
+func (a *aperture[T]) Snapshot() loadbalance.ApertureSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	snapshot := a.snapshot
+	snapshot.Peers = append([]loadbalance.AperturePeer(nil), a.snapshot.Peers...)
+
+	return snapshot
+}
 
+// rebuild just rebuilds the aperture when any arguments changed,
+// the picker is kept as it is if the aperture doesn't change,
+// otherwise a new picker is built off to the side and swapped in,
+// so Next never sees a picker being rebuilt.
+// NOTE: a.mu must be held
+func (a *aperture[T]) rebuild() {
 	if len(a.remotePeers) == 0 {
+		// nothing to pick, Next returns ErrApertureNotReady until remote peers are set
+		a.apertureIdxes = nil
+		a.apertureWeights = nil
+		a.snapshot = loadbalance.ApertureSnapshot{LogicalAperture: a.logicalAperture}
+		a.stale = false
+		if atomic.SwapInt64(&a.width, 0) > 0 {
+			a.swap(a.newPicker())
+		}
 		return
 	}
 
-	idx, ok := a.localPeersMap[a.localID]
+	remoteWidth := floatOne / float64(len(a.remotePeers))
+
+	logicalAperture := a.effectiveAperture()
+
+	var offset, apertureWidth float64
+	idx, count, ok := a.coordinate()
+	switch {
+	case !ok:
+		// the local peer is unknown, uses a random aperture
+		apertureWidth = math.Min(floatOne, float64(logicalAperture)*remoteWidth)
+		offset = a.randomOffset
+	case a.instanceCount > 0:
+		localWidth := floatOne / float64(count)
+		apertureWidth = dApertureWidth(localWidth, remoteWidth, logicalAperture)
+		offset = float64(idx) * localWidth
+	default:
+		localWidth := floatOne / float64(count)
+		apertureWidth = dApertureWidth(localWidth, remoteWidth, logicalAperture)
+		offset = float64(idx) * apertureWidth
+	}
+
+	a.band.Store(a.newBand(!ok, logicalAperture))
+
+	ring := newRing(len(a.remotePeers))
+	apertureIdxes := ring.Slice(offset, apertureWidth)
+	apertureWeights := make([]float64, 0, len(apertureIdxes))
+	for _, apertureIdx := range apertureIdxes {
+		apertureWeights = append(apertureWeights, ring.Weight(apertureIdx, offset, apertureWidth))
+	}
+
+	a.snapshot = loadbalance.ApertureSnapshot{
+		LocalIndex:        idx,
+		LocalCount:        count,
+		RemoteCount:       len(a.remotePeers),
+		LogicalAperture:   a.logicalAperture,
+		EffectiveAperture: logicalAperture,
+		Offset:            offset,
+		Width:             apertureWidth,
+		Peers:             make([]loadbalance.AperturePeer, 0, len(apertureIdxes)),
+	}
 	if !ok {
+		a.snapshot.LocalIndex = -1
+	}
+	for i, apertureIdx := range apertureIdxes {
+		a.snapshot.Peers = append(a.snapshot.Peers, loadbalance.AperturePeer{
+			Index:  apertureIdx,
+			Peer:   a.remotePeers[apertureIdx],
+			Weight: apertureWeights[i],
+		})
+	}
+
+	if !a.stale && equalInts(a.apertureIdxes, apertureIdxes) && equalFloats(a.apertureWeights, apertureWeights) {
 		return
 	}
 
-	localWidth := floatOne / float64(len(a.localPeers))
-	remoteWidth := floatOne / float64(len(a.remotePeers))
+	a.apertureIdxes = apertureIdxes
+	a.apertureWeights = apertureWeights
+	a.stale = false
+	atomic.StoreInt64(&a.width, int64(len(apertureIdxes)))
 
-	if a.logicalAperture > len(a.remotePeers) {
-		a.logicalAperture = len(a.remotePeers)
+	// the peers still in the aperture keep their state, like inflight requests
+	picker := a.newPicker()
+	for i, apertureIdx := range a.apertureIdxes {
+		picker.Add(a.remotePeers[apertureIdx], a.apertureWeights[i])
 	}
+	loadbalance.Inherit(picker, a.picker.Load().picker)
+	a.swap(picker)
+}
 
-	apertureWidth := dApertureWidth(localWidth, remoteWidth, a.logicalAperture)
-	offset := float64(idx) * apertureWidth
+// swap swaps in the picker, and closes the old one if it's an io.Closer,
+// like the observer decorator, which must not be reset while picks are in flight
+func (a *aperture[T]) swap(picker loadbalance.PickerOf[T]) {
+	if closer, ok := a.picker.Swap(&pickerHolder[T]{picker: picker}).picker.(io.Closer); ok {
+		closer.Close()
+	}
+}
 
-	ring := newRing(len(a.remotePeers))
-	a.apertureIdxes = ring.Slice(offset, apertureWidth)
+// pickerHolder holds the picker swapped atomically,
+// pickers built by the factory may be of different types
+type pickerHolder[T any] struct {
+	picker loadbalance.PickerOf[T]
+}
+
+// effectiveAperture returns the logical aperture resized by load,
+// and capped by the number of remote peers, the configured one is kept as it is
+func (a *aperture[T]) effectiveAperture() int {
+	logicalAperture := a.logicalAperture + a.resize
+	if logicalAperture > len(a.remotePeers) {
+		logicalAperture = len(a.remotePeers)
+	}
+	if logicalAperture < intOne {
+		logicalAperture = intOne
+	}
+
+	return logicalAperture
+}
 
-	a.picker.Reset()
-	for _, apertureIdx := range a.apertureIdxes {
-		weight := ring.Weight(apertureIdx, offset, apertureWidth)
-		a.picker.Add(a.remotePeers[apertureIdx], weight)
+// resizeBand is the effective dynamic aperture of the built aperture,
+// with the bounds resolved by the remote peers and the size built with,
+// so Next checks them without holding the lock
+type resizeBand struct {
+	loadbalance.DynamicAperture
+	lower, upper int
+	size         int
+}
+
+// newBand returns the effective dynamic aperture of the aperture of size,
+// the random aperture widens and shrinks by load if not configured
+// NOTE: a.mu must be held
+func (a *aperture[T]) newBand(random bool, size int) *resizeBand {
+	var band resizeBand
+	switch {
+	case a.dynamic.LowLoad > 0 || a.dynamic.HighLoad > 0:
+		band.DynamicAperture = a.dynamic
+	case random:
+		band.DynamicAperture = loadbalance.DynamicAperture{
+			Min:      a.logicalAperture,
+			LowLoad:  randomLowLoad,
+			HighLoad: randomHighLoad,
+			Cooldown: randomCooldown,
+		}
+	default:
+		return nil
 	}
+
+	band.lower, band.upper = band.Min, band.Max
+	if band.lower <= 0 {
+		band.lower = intOne
+	}
+	if band.upper <= 0 || band.upper > len(a.remotePeers) {
+		band.upper = len(a.remotePeers)
+	}
+	band.size = size
+
+	return &band
+}
+
+// coordinate returns the index of the local peer and the number of local peers
+func (a *aperture[T]) coordinate() (int, int, bool) {
+	if a.instanceCount > 0 {
+		return a.instanceID, a.instanceCount, true
+	}
+
+	if len(a.localPeers) == 0 {
+		return 0, 0, false
+	}
+
+	idx, ok := a.localPeersMap[a.localID]
+	if !ok {
+		return 0, 0, false
+	}
+
+	return idx, len(a.localPeers), true
 }
 
 // dApertureWidth calculates the actual aperture size base on logic aperture size
@@ -147,3 +614,31 @@ func dApertureWidth(localWidth, remoteWidth float64, logicalAperture int) float6
 
 	return math.Min(floatOne, width)
 }
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,10 +1,16 @@
 package aperture
 
 import (
+	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/balancer"
 )
@@ -115,10 +121,10 @@ func TestDynamic(t *testing.T) {
 		ll.SetLocalPeerID("1")
 		ll.SetLogicalAperture(2)
 
-		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture).List())
+		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture[interface{}]).List())
 
 		ll.SetLocalPeers([]string{"1", "2", "3"})
-		assert.Equal(t, []int{0, 1}, ll.(*aperture).List())
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
 
 	})
 
@@ -129,10 +135,705 @@ func TestDynamic(t *testing.T) {
 		ll.SetLocalPeerID("1")
 		ll.SetLogicalAperture(2)
 
-		assert.Equal(t, []int{0, 1}, ll.(*aperture).List())
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
 
 		ll.SetRemotePeers([]interface{}{"1", "2", "3", "4"})
-		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture).List())
+		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture[interface{}]).List())
 
 	})
 }
+
+func TestDeterministic(t *testing.T) {
+	t.Run("invalid coordinate", func(t *testing.T) {
+		ll := NewDeterministicAperture(3, 3)
+		ll.SetRemotePeers([]interface{}{"8", "9", "10"})
+
+		// the invalid coordinate is ignored, falls back to random aperture
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.NotNil(t, item)
+		assert.Equal(t, -1, ll.Snapshot().LocalIndex)
+	})
+
+	t.Run("3 client 3 server", func(t *testing.T) {
+		ll := NewDeterministicAperture(0, 3)
+		ll.SetRemotePeers([]interface{}{"8", "9", "10"})
+		ll.SetLogicalAperture(1)
+
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "8", item)
+
+		ll.SetCoordinate(1, 3)
+		item, _ = ll.Next()
+		assert.Equal(t, "9", item)
+
+		ll.SetCoordinate(2, 3)
+		item, _ = ll.Next()
+		assert.Equal(t, "10", item)
+	})
+
+	t.Run("coordinate over local peers", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetLocalPeers([]string{"1", "2", "3"})
+		ll.SetRemotePeers([]interface{}{"8", "9", "10"})
+		ll.SetLocalPeerID("1")
+		ll.SetLogicalAperture(1)
+		assert.Equal(t, []int{0}, ll.(*aperture[interface{}]).List())
+
+		ll.SetCoordinate(2, 3)
+		assert.Equal(t, []int{2}, ll.(*aperture[interface{}]).List())
+
+		ll.SetCoordinate(0, 0)
+		assert.Equal(t, []int{0}, ll.(*aperture[interface{}]).List())
+	})
+
+	t.Run("coordinate change keeps picker", func(t *testing.T) {
+		ll := NewDeterministicAperture(0, 4)
+		ll.SetRemotePeers([]interface{}{"8", "9"})
+		ll.SetLogicalAperture(1)
+		assert.Equal(t, []int{0}, ll.(*aperture[interface{}]).List())
+
+		// the same offset and width
+		ll.SetCoordinate(0, 8)
+		assert.Equal(t, []int{0}, ll.(*aperture[interface{}]).List())
+		assert.Equal(t, []float64{1}, ll.(*aperture[interface{}]).apertureWeights)
+
+		ll.SetCoordinate(1, 4)
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
+		assert.Equal(t, []float64{0.5, 0.5}, ll.(*aperture[interface{}]).apertureWeights)
+
+		// least loaded picker keeps the inflight requests
+		item, _ := ll.Next()
+		ll.SetCoordinate(2, 8)
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
+
+		next, _ := ll.Next()
+		assert.NotEqual(t, item, next)
+	})
+
+	t.Run("coordinate change keeps state", func(t *testing.T) {
+		ll := New(nil, WithLogicalAperture(2), WithCoordinate(0, 4))
+		ll.SetRemotePeers([]interface{}{"0", "1", "2", "3"})
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
+
+		dones := make([]func(balancer.DoneInfo), 0)
+		for i := 0; i < 4; i++ {
+			_, done := ll.Next()
+			dones = append(dones, done)
+		}
+
+		// the window moves, "1" keeps its inflight requests
+		ll.SetCoordinate(1, 4)
+		assert.Equal(t, []int{1, 2}, ll.(*aperture[interface{}]).List())
+		assert.Equal(t, []loadbalance.NodeStats{
+			{Item: "1", Weight: 1, Inflight: 2},
+			{Item: "2", Weight: 1},
+		}, loadbalance.Stats[interface{}](ll))
+
+		for _, done := range dones {
+			done(balancer.DoneInfo{})
+		}
+		assert.Equal(t, int64(0), loadbalance.Stats[interface{}](ll)[0].Inflight)
+	})
+
+	t.Run("uniform load", func(t *testing.T) {
+		for _, c := range []struct{ local, remote, aperture int }{
+			{3, 5, 2},
+			{5, 3, 1},
+			{7, 20, 4},
+			{10, 100, 12},
+			{100, 10, 3},
+			{12, 12, 12},
+		} {
+			load := make([]float64, c.remote)
+			remotePeers := make([]interface{}, c.remote)
+			for i := range remotePeers {
+				remotePeers[i] = i
+			}
+
+			for i := 0; i < c.local; i++ {
+				ll := NewDeterministicAperture(i, c.local)
+				ll.SetRemotePeers(remotePeers)
+				ll.SetLogicalAperture(c.aperture)
+
+				a := ll.(*aperture[interface{}])
+				for j, idx := range a.apertureIdxes {
+					load[idx] += a.apertureWeights[j]
+				}
+			}
+
+			min, max := load[0], load[0]
+			for _, l := range load {
+				min = math.Min(min, l)
+				max = math.Max(max, l)
+			}
+
+			assert.InDelta(t, 1, max/min, 1e-9, "%+v %v", c, load)
+		}
+	})
+}
+
+func TestRandom(t *testing.T) {
+	remotePeers := []interface{}{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
+	t.Run("unknown local peer", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(3)
+
+		a := ll.(*aperture[interface{}])
+		assert.Equal(t, -1, ll.Snapshot().LocalIndex)
+
+		// a random offset may intersect with one more peer
+		idxes := a.List()
+		assert.GreaterOrEqual(t, len(idxes), 3)
+		assert.LessOrEqual(t, len(idxes), 4)
+		for i := 1; i < len(idxes); i++ {
+			assert.Equal(t, (idxes[i-1]+1)%len(remotePeers), idxes[i])
+		}
+
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.NotNil(t, item)
+
+		// switches to the local peers once known
+		ll.SetLocalPeers([]string{"1", "2"})
+		ll.SetLocalPeerID("2")
+		assert.Equal(t, 1, ll.Snapshot().LocalIndex)
+		assert.Equal(t, []int{5, 6, 7, 8, 9}, a.List())
+	})
+
+	t.Run("random offset", func(t *testing.T) {
+		offsets := make(map[float64]bool)
+		for i := 0; i < 10; i++ {
+			a := NewLeastLoadedApeture().(*aperture[interface{}])
+			assert.True(t, a.randomOffset >= 0 && a.randomOffset < 1)
+			offsets[a.randomOffset] = true
+		}
+		assert.Equal(t, 10, len(offsets))
+	})
+
+	t.Run("expand", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+
+		a := ll.(*aperture[interface{}])
+		width := len(a.List())
+
+		// every resize happens after the cooldown
+		start := time.Now()
+		ticks := int64(0)
+		a.now = func() time.Time {
+			return start.Add(time.Duration(atomic.AddInt64(&ticks, 1)) * randomCooldown)
+		}
+
+		dones := make([]func(balancer.DoneInfo), 0)
+		for i := 0; i < 2*width; i++ {
+			_, done := ll.Next()
+			dones = append(dones, done)
+		}
+		assert.Equal(t, width, len(a.List()))
+
+		_, done := ll.Next()
+		dones = append(dones, done)
+		assert.Less(t, width, len(a.List()))
+
+		wg := sync.WaitGroup{}
+		mu := sync.Mutex{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, done := ll.Next()
+				mu.Lock()
+				dones = append(dones, done)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, len(remotePeers), len(a.List()))
+
+		for _, done := range dones {
+			done(balancer.DoneInfo{})
+		}
+		assert.Equal(t, int64(0), a.inflight)
+
+		// shrinks back to the logical aperture once the load is gone
+		for i := 0; i < len(remotePeers); i++ {
+			_, done := ll.Next()
+			done(balancer.DoneInfo{})
+		}
+		assert.Equal(t, width, len(a.List()))
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+
+		a := ll.(*aperture[interface{}])
+		width := len(a.List())
+
+		now := time.Now()
+		a.now = func() time.Time { return now }
+
+		dones := make([]func(balancer.DoneInfo), 0)
+		for i := 0; i < 4*width; i++ {
+			_, done := ll.Next()
+			dones = append(dones, done)
+		}
+		assert.Equal(t, width+1, len(a.List()))
+
+		// no more resizes within the cooldown
+		for _, done := range dones {
+			done(balancer.DoneInfo{})
+		}
+		_, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, width+1, len(a.List()))
+	})
+}
+
+func TestDynamicAperture(t *testing.T) {
+	remotePeers := []interface{}{"0", "1", "2", "3", "4", "5", "6", "7"}
+
+	t.Run("resize", func(t *testing.T) {
+		now := time.Now()
+		ll := NewDeterministicAperture(0, 8)
+		a := ll.(*aperture[interface{}])
+		a.now = func() time.Time { return now }
+
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+		ll.SetDynamicAperture(loadbalance.DynamicAperture{
+			Min:      2,
+			Max:      4,
+			LowLoad:  0.5,
+			HighLoad: 2,
+			Cooldown: time.Second,
+		})
+		assert.Equal(t, []int{0, 1}, a.List())
+
+		dones := make([]func(balancer.DoneInfo), 0)
+		next := func() {
+			_, done := ll.Next()
+			dones = append(dones, done)
+		}
+
+		// 4 inflight requests for 2 peers are within the band
+		for i := 0; i < 4; i++ {
+			next()
+		}
+		assert.Equal(t, []int{0, 1}, a.List())
+
+		next()
+		assert.Equal(t, []int{0, 1, 2}, a.List())
+
+		// cooldown
+		for i := 0; i < 10; i++ {
+			next()
+		}
+		assert.Equal(t, []int{0, 1, 2}, a.List())
+
+		now = now.Add(time.Second)
+		next()
+		assert.Equal(t, []int{0, 1, 2, 3}, a.List())
+
+		// max
+		now = now.Add(time.Second)
+		next()
+		assert.Equal(t, []int{0, 1, 2, 3}, a.List())
+
+		for _, done := range dones {
+			done(balancer.DoneInfo{})
+		}
+
+		// 1 inflight request for 4 peers is below the band
+		for i := 0; i < 5; i++ {
+			now = now.Add(time.Second)
+			_, done := ll.Next()
+			done(balancer.DoneInfo{})
+		}
+
+		// min
+		assert.Equal(t, []int{0, 1}, a.List())
+	})
+
+	t.Run("cooldown without lock", func(t *testing.T) {
+		ll := NewDeterministicAperture(0, 8)
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+		ll.SetDynamicAperture(loadbalance.DynamicAperture{HighLoad: 0.1, Cooldown: time.Hour})
+
+		// the first pick resizes the aperture
+		_, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture[interface{}]).List())
+
+		// picks within the cooldown never wait for an update holding the lock
+		a := ll.(*aperture[interface{}])
+		a.mu.Lock()
+		picked := make(chan struct{})
+		go func() {
+			_, done := ll.Next()
+			done(balancer.DoneInfo{})
+			close(picked)
+		}()
+		select {
+		case <-picked:
+		case <-time.After(time.Second):
+			t.Error("pick blocked by the aperture lock")
+		}
+		a.mu.Unlock()
+		<-picked
+	})
+
+	t.Run("bounds without lock", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(3)
+
+		// idle picks out of the cooldown want to shrink the random aperture at its minimum
+		a := ll.(*aperture[interface{}])
+		start := time.Now()
+		a.now = func() time.Time { return start.Add(time.Hour) }
+
+		a.mu.Lock()
+		picked := make(chan struct{})
+		go func() {
+			_, done := ll.Next()
+			done(balancer.DoneInfo{})
+			close(picked)
+		}()
+		select {
+		case <-picked:
+		case <-time.After(time.Second):
+			t.Error("pick blocked by the aperture lock")
+		}
+		a.mu.Unlock()
+		<-picked
+		assert.Equal(t, 3, ll.Snapshot().EffectiveAperture)
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		ll := NewDeterministicAperture(0, 8)
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+
+		ll.SetDynamicAperture(loadbalance.DynamicAperture{Min: 3, HighLoad: 2})
+		assert.Equal(t, []int{0, 1, 2}, ll.(*aperture[interface{}]).List())
+
+		ll.SetDynamicAperture(loadbalance.DynamicAperture{})
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
+
+		ll.SetLogicalAperture(5)
+		ll.SetDynamicAperture(loadbalance.DynamicAperture{Max: 4, HighLoad: 2})
+		assert.Equal(t, []int{0, 1, 2, 3}, ll.(*aperture[interface{}]).List())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ll := NewDeterministicAperture(0, 8)
+		ll.SetRemotePeers(remotePeers)
+		ll.SetLogicalAperture(2)
+
+		for i := 0; i < 100; i++ {
+			ll.Next()
+		}
+		assert.Equal(t, []int{0, 1}, ll.(*aperture[interface{}]).List())
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("not ready", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		snapshot := ll.Snapshot()
+		assert.Equal(t, 0, snapshot.RemoteCount)
+		assert.Empty(t, snapshot.Peers)
+	})
+
+	t.Run("local peers", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetLocalPeers([]string{"1", "2", "3"})
+		ll.SetRemotePeers([]interface{}{"8", "9", "10", "11", "12"})
+		ll.SetLocalPeerID("2")
+		ll.SetLogicalAperture(2)
+
+		snapshot := ll.Snapshot()
+		assert.Equal(t, 1, snapshot.LocalIndex)
+		assert.Equal(t, 3, snapshot.LocalCount)
+		assert.Equal(t, 5, snapshot.RemoteCount)
+		assert.Equal(t, 2, snapshot.LogicalAperture)
+		assert.InDelta(t, 2.0/3, snapshot.Offset, 1e-9)
+		assert.InDelta(t, 2.0/3, snapshot.Width, 1e-9)
+
+		remotePeers := []interface{}{"8", "9", "10", "11", "12"}
+		expected := []struct {
+			index  int
+			weight float64
+		}{{3, 2.0 / 3}, {4, 1}, {0, 1}, {1, 2.0 / 3}}
+
+		assert.Equal(t, len(expected), len(snapshot.Peers))
+		for i, peer := range snapshot.Peers {
+			assert.Equal(t, expected[i].index, peer.Index)
+			assert.Equal(t, remotePeers[peer.Index], peer.Peer)
+			assert.InDelta(t, expected[i].weight, peer.Weight, 1e-9)
+		}
+
+		// snapshot is a copy
+		snapshot.Peers[0].Weight = 0
+		assert.InDelta(t, 2.0/3, ll.Snapshot().Peers[0].Weight, 1e-9)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetRemotePeers([]interface{}{"8", "9", "10", "11"})
+		ll.SetLogicalAperture(2)
+
+		snapshot := ll.Snapshot()
+		assert.Equal(t, -1, snapshot.LocalIndex)
+		assert.Equal(t, 0, snapshot.LocalCount)
+		assert.Equal(t, ll.(*aperture[interface{}]).randomOffset, snapshot.Offset)
+		assert.Equal(t, 0.5, snapshot.Width)
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	t.Run("stale local peers", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetLocalPeers([]string{"1", "2", "3"})
+		ll.SetRemotePeers([]interface{}{"8", "9", "10"})
+		ll.SetLocalPeerID("3")
+		ll.SetLogicalAperture(1)
+		assert.Equal(t, []int{2}, ll.(*aperture[interface{}]).List())
+
+		// "3" is gone, falls back to random aperture
+		ll.SetLocalPeers([]string{"1", "2"})
+		_, ok := ll.(*aperture[interface{}]).localPeersMap["3"]
+		assert.False(t, ok)
+		assert.Equal(t, -1, ll.Snapshot().LocalIndex)
+	})
+
+	t.Run("empty remote peers", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetLogicalAperture(1)
+		ll.Update("2", []string{"1", "2", "3"}, []interface{}{"8", "9", "10"})
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.NotNil(t, item)
+
+		// all remote peers are gone
+		ll.SetRemotePeers(nil)
+		item, _, err := ll.(*aperture[interface{}]).NextErr()
+		assert.Nil(t, item)
+		assert.Equal(t, loadbalance.ErrApertureNotReady, err)
+		assert.Empty(t, ll.(*aperture[interface{}]).List())
+		assert.Empty(t, loadbalance.Stats[interface{}](ll))
+
+		snapshot := ll.Snapshot()
+		assert.Equal(t, 0, snapshot.RemoteCount)
+		assert.Empty(t, snapshot.Peers)
+
+		// and come back
+		ll.SetRemotePeers([]interface{}{"11", "12", "13"})
+		item, _ = ll.Next()
+		assert.Equal(t, "12", item)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		ll := NewLeastLoadedApeture()
+		ll.SetLogicalAperture(1)
+		ll.Update("2", []string{"1", "2", "3"}, []interface{}{"8", "9", "10"})
+		assert.Equal(t, []int{1}, ll.(*aperture[interface{}]).List())
+
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "9", item)
+
+		// the same aperture but different remote peers
+		ll.Update("2", []string{"1", "2", "3"}, []interface{}{"11", "12", "13"})
+		item, _ = ll.Next()
+		assert.Equal(t, "12", item)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		ll := NewPeakEwmaAperture()
+		ll.Update("1", []string{"1", "2"}, []interface{}{"8", "9", "10", "11"})
+
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+
+					item, done := ll.Next()
+					done(balancer.DoneInfo{})
+					assert.NotNil(t, item)
+				}
+			}()
+		}
+
+		for i := 0; i < 200; i++ {
+			ll.Update(fmt.Sprint(i%2+1), []string{"1", "2"}, []interface{}{"8", "9", "10", "11", fmt.Sprint(i)})
+			ll.SetLocalPeerID(fmt.Sprint(i%2 + 1))
+			ll.SetRemotePeers([]interface{}{"8", "9", "10", "11"})
+			ll.SetLogicalAperture(i%4 + 1)
+			ll.SetCoordinate(i%3, 3)
+			ll.SetCoordinate(0, 0)
+			ll.Snapshot()
+		}
+
+		close(stop)
+		wg.Wait()
+	})
+}
+
+func TestEffectiveAperture(t *testing.T) {
+	ll := NewLeastLoadedApeture()
+	ll.SetLogicalAperture(4)
+	ll.Update("1", []string{"1"}, []interface{}{"8", "9", "10", "11", "12", "13"})
+
+	snapshot := ll.Snapshot()
+	assert.Equal(t, 4, snapshot.LogicalAperture)
+	assert.Equal(t, 4, snapshot.EffectiveAperture)
+
+	// scales down during a deploy
+	ll.SetRemotePeers([]interface{}{"8", "9"})
+	snapshot = ll.Snapshot()
+	assert.Equal(t, 4, snapshot.LogicalAperture)
+	assert.Equal(t, 2, snapshot.EffectiveAperture)
+
+	// scales up again, the configured aperture comes back
+	ll.Update("1", []string{"1", "2", "3"}, []interface{}{"8", "9", "10", "11", "12", "13"})
+	snapshot = ll.Snapshot()
+	assert.Equal(t, 4, snapshot.LogicalAperture)
+	assert.Equal(t, 4, snapshot.EffectiveAperture)
+	assert.Equal(t, []int{0, 1, 2, 3}, ll.(*aperture[interface{}]).List())
+}
+
+// countingPicker is a decorator which counts the picks of the inner picker
+type countingPicker struct {
+	loadbalance.Picker
+	picks *int64
+}
+
+func (p *countingPicker) Next() (interface{}, func(balancer.DoneInfo)) {
+	atomic.AddInt64(p.picks, 1)
+	return p.Picker.Next()
+}
+
+func TestNew(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		ll := New(nil)
+		ll.Update("1", []string{"1"}, []interface{}{"8"})
+
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "8", item)
+	})
+
+	t.Run("decorator", func(t *testing.T) {
+		picks := int64(0)
+		built := 0
+		ll := New(func() loadbalance.Picker {
+			built++
+			return &countingPicker{Picker: roundrobin.NewSmoothRoundrobin(), picks: &picks}
+		}, WithLogicalAperture(1), WithCoordinate(1, 3))
+		ll.SetRemotePeers([]interface{}{"8", "9", "10"})
+
+		for i := 0; i < 10; i++ {
+			item, done := ll.Next()
+			done(balancer.DoneInfo{})
+			assert.Equal(t, "9", item)
+		}
+
+		assert.Equal(t, int64(10), picks)
+		assert.Equal(t, 2, built)
+	})
+
+	t.Run("candidates", func(t *testing.T) {
+		ll := New(nil, WithLogicalAperture(2), WithCoordinate(0, 1))
+		_, _, _, err := ll.NextCandidates()
+		assert.Equal(t, loadbalance.ErrApertureNotReady, err)
+
+		ll.SetRemotePeers([]interface{}{"8", "9"})
+		item, done, candidates, err := ll.NextCandidates()
+		assert.NoError(t, err)
+		assert.Len(t, candidates, 2)
+		assert.Equal(t, item, candidates[0].Item)
+		assert.Equal(t, int64(1), ll.(*aperture[interface{}]).inflight)
+		done(balancer.DoneInfo{})
+	})
+
+	t.Run("mixed pickers", func(t *testing.T) {
+		built := 0
+		ll := New(func() loadbalance.Picker {
+			built++
+			if built%2 == 0 {
+				return p2c.NewPeakEwma()
+			}
+			return p2c.NewLeastLoaded()
+		}, WithLogicalAperture(1), WithCoordinate(0, 3))
+
+		// pickers of different types are swapped in
+		for i := 0; i < 3; i++ {
+			ll.SetRemotePeers([]interface{}{"8", "9", fmt.Sprint(i)})
+			item, done := ll.Next()
+			done(balancer.DoneInfo{})
+			assert.Equal(t, "8", item)
+		}
+		assert.Equal(t, 4, built)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		ll := New(p2c.NewPeakEwma,
+			WithLogicalAperture(3),
+			WithCoordinate(0, 4),
+			WithDynamicAperture(loadbalance.DynamicAperture{Min: 4, HighLoad: 2}),
+		)
+		ll.SetRemotePeers([]interface{}{"0", "1", "2", "3", "4", "5", "6", "7"})
+
+		snapshot := ll.Snapshot()
+		assert.Equal(t, 0, snapshot.LocalIndex)
+		assert.Equal(t, 4, snapshot.LocalCount)
+		assert.Equal(t, 3, snapshot.LogicalAperture)
+		assert.Equal(t, 4, snapshot.EffectiveAperture)
+		assert.Equal(t, []int{0, 1, 2, 3}, ll.(*aperture[interface{}]).List())
+	})
+}
+
+func TestNewOf(t *testing.T) {
+	ll := NewOf(roundrobin.NewSmoothRoundrobinOf[string], WithLogicalAperture(1))
+	item, done := ll.Next()
+	done(balancer.DoneInfo{})
+	assert.Equal(t, "", item)
+
+	ll.Update("1", []string{"0", "1", "2"}, []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"})
+	for i := 0; i < 10; i++ {
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "10.0.0.2:80", item)
+	}
+	assert.Equal(t, int64(0), ll.(*aperture[string]).inflight)
+
+	snapshot := ll.Snapshot()
+	assert.Equal(t, "10.0.0.2:80", snapshot.Peers[0].Peer)
+}
+
+func TestStats(t *testing.T) {
+	ll := New(roundrobin.NewSmoothRoundrobin, WithLogicalAperture(1))
+	assert.Empty(t, ll.(loadbalance.Statser).Stats())
+
+	ll.Update("1", []string{"0", "1"}, []interface{}{"8", "9"})
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: "9", Weight: 1, EffectiveWeight: 1},
+	}, ll.(loadbalance.Statser).Stats())
+}
@@ -0,0 +1,76 @@
+// Command aperture-plan prints the aperture of every local peer,
+// the connections and load share of every remote peer before deploying.
+//
+//	aperture-plan -local 10 -remote 30 -aperture 12
+//	aperture-plan -local-file clients.txt -remote-file servers.txt -aperture 6
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var (
+		localCount      = flag.Int("local", 0, "number of local peers")
+		remoteCount     = flag.Int("remote", 0, "number of remote peers")
+		localFile       = flag.String("local-file", "", "file of local peer ids, one per line, overrides -local")
+		remoteFile      = flag.String("remote-file", "", "file of remote peers, one per line, overrides -remote")
+		logicalAperture = flag.Int("aperture", 12, "logical aperture")
+		coordinate      = flag.Bool("coordinate", false, "map local peers by coordinate like the deterministic aperture")
+	)
+	flag.Parse()
+
+	localPeers, err := peers(*localFile, *localCount, "local")
+	if err != nil {
+		exit(err)
+	}
+
+	remotePeers, err := peers(*remoteFile, *remoteCount, "remote")
+	if err != nil {
+		exit(err)
+	}
+
+	if len(localPeers) == 0 || len(remotePeers) == 0 || *logicalAperture <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	newPlan(localPeers, remotePeers, *logicalAperture, *coordinate).print(os.Stdout)
+}
+
+// peers reads peers from file, or generates count peers named by prefix
+func peers(file string, count int, prefix string) ([]string, error) {
+	if file == "" {
+		peers := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			peers = append(peers, fmt.Sprintf("%s-%d", prefix, i))
+		}
+
+		return peers, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	peers := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if peer := strings.TrimSpace(scanner.Text()); peer != "" && !strings.HasPrefix(peer, "#") {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers, scanner.Err()
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "aperture-plan:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/aperture"
+)
+
+// plan is the aperture of every local peer
+type plan struct {
+	localPeers  []string
+	remotePeers []string
+	snapshots   []loadbalance.ApertureSnapshot
+
+	// connections of every remote peer
+	connections []int
+	// load share of every remote peer, sums up to 1
+	loads []float64
+}
+
+// newPlan builds the aperture of every local peer in the same way as clients do,
+// by local peers or by coordinate like the deterministic aperture
+func newPlan(localPeers, remotePeers []string, logicalAperture int, coordinate bool) *plan {
+	p := &plan{
+		localPeers:  localPeers,
+		remotePeers: remotePeers,
+		connections: make([]int, len(remotePeers)),
+		loads:       make([]float64, len(remotePeers)),
+	}
+
+	peers := make([]interface{}, 0, len(remotePeers))
+	for _, peer := range remotePeers {
+		peers = append(peers, peer)
+	}
+
+	for i, local := range localPeers {
+		var a aperture.Aperture
+		if coordinate {
+			a = aperture.NewDeterministicAperture(i, len(localPeers))
+			a.SetLogicalAperture(logicalAperture)
+			a.SetRemotePeers(peers)
+		} else {
+			a = aperture.NewLeastLoadedApeture()
+			a.SetLogicalAperture(logicalAperture)
+			a.Update(local, localPeers, peers)
+		}
+
+		snapshot := a.Snapshot()
+		p.snapshots = append(p.snapshots, snapshot)
+
+		// every local peer sends the same amount of traffic
+		total := float64(0)
+		for _, peer := range snapshot.Peers {
+			total += peer.Weight
+		}
+
+		for _, peer := range snapshot.Peers {
+			p.connections[peer.Index]++
+			p.loads[peer.Index] += peer.Weight / total / float64(len(localPeers))
+		}
+	}
+
+	return p
+}
+
+// print prints the subsets, the connections and load share of remote peers and the summary
+func (p *plan) print(w io.Writer) {
+	fmt.Fprintf(w, "local peers: %d, remote peers: %d\n\n", len(p.localPeers), len(p.remotePeers))
+
+	fmt.Fprintln(w, "subsets:")
+	for i, snapshot := range p.snapshots {
+		peers := make([]string, 0, len(snapshot.Peers))
+		for _, peer := range snapshot.Peers {
+			peers = append(peers, fmt.Sprintf("%s(%.2f)", p.remotePeers[peer.Index], peer.Weight))
+		}
+
+		fmt.Fprintf(w, "  %s: offset=%.4f width=%.4f aperture=%d peers=[%s]\n",
+			p.localPeers[i], snapshot.Offset, snapshot.Width, snapshot.EffectiveAperture, strings.Join(peers, " "))
+	}
+
+	fmt.Fprintln(w, "\nremote peers:")
+	for i, peer := range p.remotePeers {
+		fmt.Fprintf(w, "  %s: connections=%d load=%.2f%%\n", peer, p.connections[i], p.loads[i]*100)
+	}
+
+	s := p.summary()
+	fmt.Fprintln(w, "\nsummary:")
+	fmt.Fprintf(w, "  total connections: %d\n", s.totalConnections)
+	fmt.Fprintf(w, "  connections per local peer: min=%d max=%d\n", s.minLocalConnections, s.maxLocalConnections)
+	fmt.Fprintf(w, "  connections per remote peer: min=%d max=%d\n", s.minRemoteConnections, s.maxRemoteConnections)
+	fmt.Fprintf(w, "  load per remote peer: min=%.2f%% max=%.2f%% ratio=%.4f\n", s.minLoad*100, s.maxLoad*100, s.loadRatio)
+}
+
+type summary struct {
+	totalConnections     int
+	minLocalConnections  int
+	maxLocalConnections  int
+	minRemoteConnections int
+	maxRemoteConnections int
+	minLoad              float64
+	maxLoad              float64
+	// loadRatio is max load / min load, +Inf if any remote peer gets no load
+	loadRatio float64
+}
+
+func (p *plan) summary() summary {
+	s := summary{
+		minLocalConnections:  math.MaxInt32,
+		minRemoteConnections: math.MaxInt32,
+		minLoad:              math.Inf(1),
+	}
+
+	for _, snapshot := range p.snapshots {
+		n := len(snapshot.Peers)
+		s.totalConnections += n
+		s.minLocalConnections = minInt(s.minLocalConnections, n)
+		s.maxLocalConnections = maxInt(s.maxLocalConnections, n)
+	}
+
+	for i := range p.remotePeers {
+		s.minRemoteConnections = minInt(s.minRemoteConnections, p.connections[i])
+		s.maxRemoteConnections = maxInt(s.maxRemoteConnections, p.connections[i])
+		s.minLoad = math.Min(s.minLoad, p.loads[i])
+		s.maxLoad = math.Max(s.maxLoad, p.loads[i])
+	}
+
+	s.loadRatio = s.maxLoad / s.minLoad
+
+	return s
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	localPeers, _ := peers("", 3, "local")
+	remotePeers, _ := peers("", 5, "remote")
+
+	for _, coordinate := range []bool{false, true} {
+		p := newPlan(localPeers, remotePeers, 2, coordinate)
+
+		s := p.summary()
+		assert.Equal(t, 12, s.totalConnections)
+		assert.Equal(t, 4, s.minLocalConnections)
+		assert.Equal(t, 4, s.maxLocalConnections)
+		assert.Equal(t, 2, s.minRemoteConnections)
+		assert.Equal(t, 3, s.maxRemoteConnections)
+		assert.InDelta(t, 0.2, s.minLoad, 1e-9)
+		assert.InDelta(t, 0.2, s.maxLoad, 1e-9)
+		assert.InDelta(t, 1, s.loadRatio, 1e-9)
+
+		buf := &bytes.Buffer{}
+		p.print(buf)
+		assert.Contains(t, buf.String(), "local peers: 3, remote peers: 5")
+		assert.Contains(t, buf.String(), "  local-0: offset=0.0000 width=0.6667 aperture=2 peers=[remote-0(1.00) remote-1(1.00) remote-2(1.00) remote-3(0.33)]")
+		assert.Contains(t, buf.String(), "  remote-1: connections=3 load=20.00%")
+		assert.Contains(t, buf.String(), "  load per remote peer: min=20.00% max=20.00% ratio=1.0000")
+	}
+}
+
+func TestPlanSinglePeer(t *testing.T) {
+	localPeers, _ := peers("", 1, "local")
+	remotePeers, _ := peers("", 10, "remote")
+
+	p := newPlan(localPeers, remotePeers, 2, false)
+
+	// a single local peer connects to all remote peers
+	s := p.summary()
+	assert.Equal(t, 10, s.totalConnections)
+	assert.InDelta(t, 1, s.loadRatio, 1e-9)
+
+	remotePeers, _ = peers("", 1, "remote")
+	p = newPlan(localPeers, remotePeers, 12, false)
+
+	s = p.summary()
+	assert.Equal(t, 1, s.totalConnections)
+	assert.Equal(t, 1, s.maxRemoteConnections)
+	assert.InDelta(t, 1, s.maxLoad, 1e-9)
+}
+
+func TestPeersFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "peers")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("# servers\n10.0.0.1:80\n\n 10.0.0.2:80 \n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	remotePeers, err := peers(f.Name(), 5, "remote")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.2:80"}, remotePeers)
+
+	_, err = peers(f.Name()+".missing", 0, "remote")
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package grpcpicker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hnlq715/go-loadbalance"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type picker struct {
+	nexter loadbalance.NexterOf[balancer.SubConn]
+}
+
+// New returns a gRPC balancer.Picker which picks SubConns by the nexter,
+// the errors of the nexter are mapped by Error.
+// The PickInfo is not used, see NewInfo and NewContext for the pickers using it.
+func New(nexter loadbalance.NexterOf[balancer.SubConn]) balancer.Picker {
+	return &picker{nexter: nexter}
+}
+
+// Pick returns the SubConn selected by the nexter
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	sc, done, err := loadbalance.NextErr(p.nexter)
+	if err != nil {
+		return balancer.PickResult{}, Error(err)
+	}
+
+	return balancer.PickResult{SubConn: sc, Done: done}, nil
+}
+
+// CandidatesPicker is a balancer.Picker which also tells the candidates compared for the pick
+type CandidatesPicker interface {
+	balancer.Picker
+	// PickCandidates returns the picked SubConn and the candidates compared,
+	// the selected one comes first
+	PickCandidates(balancer.PickInfo) (balancer.PickResult, []loadbalance.NodeStatsOf[balancer.SubConn], error)
+}
+
+var _ CandidatesPicker = (*picker)(nil)
+
+// PickCandidates returns the SubConn selected by the nexter and the candidates compared,
+// which are nil if the nexter is not a CandidaterOf
+func (p *picker) PickCandidates(balancer.PickInfo) (balancer.PickResult, []loadbalance.NodeStatsOf[balancer.SubConn], error) {
+	sc, done, candidates, err := loadbalance.NextCandidates(p.nexter)
+	if err != nil {
+		return balancer.PickResult{}, nil, Error(err)
+	}
+
+	return balancer.PickResult{SubConn: sc, Done: done}, candidates, nil
+}
+
+// InfoPickerOf is anything able to select the next item by the gRPC PickInfo,
+// like route.RouterOf routing by the outgoing metadata
+type InfoPickerOf[T any] interface {
+	// Pick returns the next selected item for the RPC.
+	Pick(balancer.PickInfo) (T, func(balancer.DoneInfo))
+}
+
+// ContextNexterOf is anything able to select the next item by the RPC context,
+// like sticky.StickyOf pinning sessions
+type ContextNexterOf[T any] interface {
+	// NextContext returns the next selected item for the RPC context.
+	NextContext(context.Context) (T, func(balancer.DoneInfo))
+}
+
+type funcPicker struct {
+	pick func(balancer.PickInfo) (balancer.SubConn, func(balancer.DoneInfo))
+}
+
+// NewInfo returns a gRPC balancer.Picker which picks SubConns by the PickInfo,
+// a nil SubConn is reported as ErrNoSubConnAvailable
+func NewInfo(picker InfoPickerOf[balancer.SubConn]) balancer.Picker {
+	return &funcPicker{pick: picker.Pick}
+}
+
+// NewContext returns a gRPC balancer.Picker which picks SubConns by the RPC context,
+// a nil SubConn is reported as ErrNoSubConnAvailable
+func NewContext(nexter ContextNexterOf[balancer.SubConn]) balancer.Picker {
+	return &funcPicker{pick: func(info balancer.PickInfo) (balancer.SubConn, func(balancer.DoneInfo)) {
+		ctx := info.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		return nexter.NextContext(ctx)
+	}}
+}
+
+// Pick returns the SubConn selected for the PickInfo
+func (p *funcPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	sc, done := p.pick(info)
+	if sc == nil {
+		return balancer.PickResult{}, Error(loadbalance.ErrNoAvailableItem)
+	}
+
+	return balancer.PickResult{SubConn: sc, Done: done}, nil
+}
+
+// Error maps the errors of loadbalance to the ones known by gRPC,
+// ErrNoAvailableItem and ErrApertureNotReady become ErrNoSubConnAvailable,
+// so gRPC blocks the RPC until a new picker is built,
+// ErrAllEjected becomes an Unavailable status, so the RPC fails fast
+// unless it's wait for ready, other errors are returned as they are.
+func Error(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, loadbalance.ErrNoAvailableItem), errors.Is(err, loadbalance.ErrApertureNotReady):
+		return balancer.ErrNoSubConnAvailable
+	case errors.Is(err, loadbalance.ErrAllEjected):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+package grpcpicker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/aperture"
+	"github.com/hnlq715/go-loadbalance/grpcpicker"
+	"github.com/hnlq715/go-loadbalance/priority"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/route"
+	"github.com/hnlq715/go-loadbalance/sticky"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+type subConn struct {
+	addr string
+}
+
+func (sc *subConn) UpdateAddresses([]resolver.Address) {}
+
+func (sc *subConn) Connect() {}
+
+func TestPicker(t *testing.T) {
+	t.Run("picked", func(t *testing.T) {
+		sc := &subConn{addr: "10.0.0.1:80"}
+		rr := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+		rr.Add(sc, 1)
+
+		result, err := grpcpicker.New(rr).Pick(balancer.PickInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, sc, result.SubConn)
+	})
+
+	t.Run("no available item", func(t *testing.T) {
+		rr := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+
+		_, err := grpcpicker.New(rr).Pick(balancer.PickInfo{})
+		assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+	})
+
+	t.Run("aperture not ready", func(t *testing.T) {
+		a := aperture.NewOf[balancer.SubConn](nil)
+
+		_, _, err := a.(loadbalance.ErrNexterOf[balancer.SubConn]).NextErr()
+		assert.Equal(t, loadbalance.ErrApertureNotReady, err)
+
+		_, err = grpcpicker.New(a).Pick(balancer.PickInfo{})
+		assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+	})
+
+	t.Run("all ejected", func(t *testing.T) {
+		sc := &subConn{addr: "10.0.0.1:80"}
+		info := loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+		p := priority.NewOf[balancer.SubConn](info)
+		p.Add(sc, 1, info)
+		p.SetHealthy(sc, false)
+
+		_, _, err := p.NextErr()
+		assert.Equal(t, loadbalance.ErrAllEjected, err)
+
+		_, err = grpcpicker.New(p).Pick(balancer.PickInfo{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestNewInfo(t *testing.T) {
+	stable, canary := &subConn{addr: "10.0.0.1:80"}, &subConn{addr: "10.0.0.2:80"}
+	stables := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+	stables.Add(stable, 1)
+	canaries := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+	canaries.Add(canary, 1)
+
+	r, err := route.NewOf(route.Config{
+		Rules:   []route.Rule{{Headers: []route.HeaderMatcher{{Name: "x-canary", Present: true}}, Target: "canary"}},
+		Default: "stable",
+	}, map[string]loadbalance.NexterOf[balancer.SubConn]{"canary": canaries, "stable": stables})
+	assert.NoError(t, err)
+	p := grpcpicker.NewInfo(r)
+
+	// the metadata reaches the router
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-canary", "1")
+	result, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.NoError(t, err)
+	assert.Equal(t, canary, result.SubConn)
+
+	result, err = p.Pick(balancer.PickInfo{Ctx: context.Background()})
+	assert.NoError(t, err)
+	assert.Equal(t, stable, result.SubConn)
+}
+
+func TestNewContext(t *testing.T) {
+	s := sticky.NewOf(roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]())
+	p := grpcpicker.NewContext(s)
+
+	_, err := p.Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+
+	s.Add(&subConn{addr: "10.0.0.1:80"}, 1)
+	s.Add(&subConn{addr: "10.0.0.2:80"}, 1)
+
+	// the session is pinned through the picker
+	ctx := sticky.NewContext(context.Background(), "user-1")
+	first, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		result, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+		assert.NoError(t, err)
+		assert.Equal(t, first.SubConn, result.SubConn)
+	}
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestError(t *testing.T) {
+	unknown := errors.New("unknown")
+
+	assert.Nil(t, grpcpicker.Error(nil))
+	assert.Equal(t, unknown, grpcpicker.Error(unknown))
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, grpcpicker.Error(loadbalance.ErrNoAvailableItem))
+}
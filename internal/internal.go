@@ -1,8 +1,53 @@
 package internal
 
-import "google.golang.org/grpc/balancer"
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/grpc/balancer"
+)
 
 var (
 	// EmptyDoneFunc is a empty done function
 	EmptyDoneFunc = func(balancer.DoneInfo) {}
 )
+
+// Identity is the default identity of items, which is the item itself if comparable,
+// like pointers of SubConns, or fmt.Sprint of it for other values like slices.
+// Items neither comparable nor plain values need a key function.
+func Identity[T any](item T) interface{} {
+	v := interface{}(item)
+	if v == nil || reflect.ValueOf(v).Comparable() {
+		return v
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Name returns a printable name of the item for metrics and debugging,
+// pointers without String are named by their address so their contents are never read
+func Name(item interface{}) string {
+	switch v := item.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	if v := reflect.ValueOf(item); v.Kind() == reflect.Pointer && !v.IsNil() {
+		return fmt.Sprintf("%p", item)
+	}
+
+	return fmt.Sprint(item)
+}
+
+// IdentityOf returns the identity function by key, Identity if nil
+func IdentityOf[T any](key func(T) string) func(T) interface{} {
+	if key == nil {
+		return Identity[T]
+	}
+
+	return func(item T) interface{} {
+		return key(item)
+	}
+}
@@ -1,15 +1,84 @@
 package loadbalance
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
 	"google.golang.org/grpc/balancer"
 )
 
-// Aperture support map local peers to remote peers
+// NexterOf is anything able to select the next item of type T,
+// like PickerOf, SetOf and ApertureOf
+type NexterOf[T any] interface {
+	// Next returns next selected item.
+	Next() (T, func(balancer.DoneInfo))
+}
+
+// Nexter is a NexterOf items of any type
+type Nexter = NexterOf[interface{}]
+
+var (
+	// ErrNoAvailableItem means there is no item to pick
+	ErrNoAvailableItem = errors.New("loadbalance: no available item")
+	// ErrAllEjected means there are items but all of them are ejected
+	ErrAllEjected = errors.New("loadbalance: all items are ejected")
+	// ErrApertureNotReady means the aperture is not built yet
+	ErrApertureNotReady = errors.New("loadbalance: aperture is not ready")
+)
+
+// ErrNexterOf is an optional interface of NexterOf,
+// which tells why nothing is picked instead of returning the zero value
+type ErrNexterOf[T any] interface {
+	// NextErr returns next selected item, or an error if nothing is picked.
+	NextErr() (T, func(balancer.DoneInfo), error)
+}
+
+// NextErr returns the next selected item of n, by NextErr if n is an ErrNexterOf,
+// otherwise a nil item is reported as ErrNoAvailableItem
+func NextErr[T any](n NexterOf[T]) (T, func(balancer.DoneInfo), error) {
+	if en, ok := n.(ErrNexterOf[T]); ok {
+		return en.NextErr()
+	}
+
+	item, done := n.Next()
+	if interface{}(item) == nil {
+		return item, done, ErrNoAvailableItem
+	}
+
+	return item, done, nil
+}
+
+// CandidaterOf is an optional interface of NexterOf,
+// which tells the candidates compared to select the item,
+// like the two random choices of p2c, the selected one comes first
+type CandidaterOf[T any] interface {
+	// NextCandidates returns next selected item and the candidates compared,
+	// or an error if nothing is picked.
+	NextCandidates() (T, func(balancer.DoneInfo), []NodeStatsOf[T], error)
+}
+
+// Candidater is a CandidaterOf items of any type
+type Candidater = CandidaterOf[interface{}]
+
+// NextCandidates returns the next selected item of n and the candidates compared if n is a CandidaterOf,
+// otherwise the candidates are nil
+func NextCandidates[T any](n NexterOf[T]) (T, func(balancer.DoneInfo), []NodeStatsOf[T], error) {
+	if c, ok := n.(CandidaterOf[T]); ok {
+		return c.NextCandidates()
+	}
+
+	item, done, err := NextErr(n)
+	return item, done, nil, err
+}
+
+// ApertureOf support map local peers to remote peers
 // to divide remote peers into subsets
 // to separate services into small sets and reduce the total connections
-type Aperture interface {
+type ApertureOf[T any] interface {
 	// Next returns next selected item.
-	Next() (interface{}, func(balancer.DoneInfo))
+	Next() (T, func(balancer.DoneInfo))
 	// Set logical aperture
 	SetLogicalAperture(int)
 	// Set local peer id
@@ -17,38 +86,307 @@ type Aperture interface {
 	// Set local peers.
 	SetLocalPeers([]string)
 	// Set remote peers.
-	SetRemotePeers([]interface{})
+	SetRemotePeers([]T)
+}
+
+// Aperture is an ApertureOf items of any type
+type Aperture = ApertureOf[interface{}]
+
+// DynamicApertureSetter is an optional interface of ApertureOf,
+// which resizes the logical aperture by load
+type DynamicApertureSetter interface {
+	// Set dynamic aperture to resize the logical aperture by load
+	SetDynamicAperture(DynamicAperture)
+}
+
+// CoordinateSetter is an optional interface of ApertureOf,
+// which maps the local peer by its coordinate
+type CoordinateSetter interface {
+	// Set coordinate of the local peer instead of local peer id and local peers,
+	// with instance id in [0, instance count)
+	SetCoordinate(int, int)
+}
+
+// ApertureUpdaterOf is an optional interface of ApertureOf,
+// which rebuilds the aperture only once for all the peers
+type ApertureUpdaterOf[T any] interface {
+	// Update local peer id, local peers and remote peers at once.
+	Update(string, []string, []T)
+}
+
+// ApertureUpdater is an ApertureUpdaterOf items of any type
+type ApertureUpdater = ApertureUpdaterOf[interface{}]
+
+// Snapshotter is an optional interface of ApertureOf,
+// which reports the state of the aperture for debugging
+type Snapshotter interface {
+	// Snapshot returns the current state of the aperture
+	Snapshot() ApertureSnapshot
+}
+
+// SubsetterOf divides remote peers into fixed-size subsets for local peers,
+// it shares the same local and remote peers setters with Aperture
+type SubsetterOf[T any] interface {
+	// Next returns next selected item.
+	Next() (T, func(balancer.DoneInfo))
+	// Set subset size
+	SetSubsetSize(int)
+	// Set local peer id
+	SetLocalPeerID(string)
+	// Set local peers.
+	SetLocalPeers([]string)
+	// Set remote peers.
+	SetRemotePeers([]T)
+	// Subset returns the remote peers selected for the local peer
+	Subset() []T
+}
+
+// Subsetter is a SubsetterOf items of any type
+type Subsetter = SubsetterOf[interface{}]
+
+// AperturePeer is a remote peer selected by the aperture
+type AperturePeer struct {
+	// Index of the peer in the remote peers
+	Index int `json:"index"`
+	// Peer, the remote peer
+	Peer interface{} `json:"peer"`
+	// Weight, the ratio of the peer covered by the aperture in the ring
+	Weight float64 `json:"weight"`
+}
+
+// ApertureSnapshot is the state of the aperture when it was last built
+type ApertureSnapshot struct {
+	// LocalIndex, index of the local peer, or -1 for random aperture
+	LocalIndex int `json:"local_index"`
+	// LocalCount, number of the local peers, or 0 for random aperture
+	LocalCount int `json:"local_count"`
+	// RemoteCount, number of the remote peers
+	RemoteCount int `json:"remote_count"`
+	// LogicalAperture, the configured logical aperture
+	LogicalAperture int `json:"logical_aperture"`
+	// EffectiveAperture, the logical aperture resized by load
+	// and capped by the number of remote peers
+	EffectiveAperture int `json:"effective_aperture"`
+	// Offset, the start of the aperture in the ring [0.0, 1.0)
+	Offset float64 `json:"offset"`
+	// Width, the effective width of the aperture in the ring (0.0, 1.0]
+	Width float64 `json:"width"`
+	// Peers, the selected remote peers
+	Peers []AperturePeer `json:"peers"`
+}
+
+// DynamicAperture resizes the logical aperture by load,
+// which is the inflight requests per remote peer in the aperture.
+// The aperture widens by one peer when the load exceeds HighLoad,
+// and narrows by one peer when the load drops below LowLoad.
+type DynamicAperture struct {
+	// Min, the min logical aperture, 1 if zero
+	Min int
+	// Max, the max logical aperture, the number of remote peers if zero
+	Max int
+	// LowLoad, the low watermark, never narrows if zero
+	LowLoad float64
+	// HighLoad, the high watermark, never widens if zero
+	HighLoad float64
+	// Cooldown, the min interval between two resizes
+	Cooldown time.Duration
 }
 
 // SetInfo contains region, zone and set
 type SetInfo struct {
 	// Name, app name defined as set
-	Name string
+	Name string `json:"name"`
 	// Region, like `bj(beijing)` or `sh(shanghai)`
-	Region string
+	Region string `json:"region,omitempty"`
+	// Zone, availability zone in the region, like `bj-a` or `bj-b`
+	Zone string `json:"zone,omitempty"`
 	// UnitName, unit name defined as subsets
-	UnitName string
+	UnitName string `json:"unit_name,omitempty"`
+	// Labels, arbitrary key/value pairs like `env=prod`
+	Labels Labels `json:"labels,omitempty"`
+}
+
+// Labels is the canonical form of key/value pairs sorted by key,
+// like `canary=true,env=prod`, so SetInfo is still comparable.
+// NOTE: keys and values must not contain `,` or `=`
+type Labels string
+
+// NewLabels returns the canonical Labels of the key/value pairs
+func NewLabels(labels map[string]string) Labels {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+
+	return Labels(strings.Join(pairs, ","))
+}
+
+// Map returns the key/value pairs of the labels
+func (l Labels) Map() map[string]string {
+	labels := make(map[string]string)
+	if l == "" {
+		return labels
+	}
+
+	for _, pair := range strings.Split(string(l), ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		labels[key] = value
+	}
+
+	return labels
 }
 
-// Set supports divide remote peers into subsets
+// SetOf supports divide remote peers into subsets
 // based on region, zone and set info
-type Set interface {
+type SetOf[T any] interface {
 	// Next returns next selected item.
-	Next() (interface{}, func(balancer.DoneInfo))
+	Next() (T, func(balancer.DoneInfo))
 	// Add a weighted item with set info.
-	Add(interface{}, float64, SetInfo)
+	Add(T, float64, SetInfo)
 	// Reset this picker
 	Reset()
 }
 
-// Picker supports multiple algorithms for load balance,
+// Set is a SetOf items of any type
+type Set = SetOf[interface{}]
+
+// PickerOf supports multiple algorithms for load balance,
 // uses the ideas behind the "power of 2 choices"
 // to select two nodes from the underlying vector.
-type Picker interface {
+type PickerOf[T any] interface {
 	// Next returns next selected item.
-	Next() (interface{}, func(balancer.DoneInfo))
+	Next() (T, func(balancer.DoneInfo))
 	// Add a weighted item.
-	Add(interface{}, float64)
+	Add(T, float64)
 	// Reset this picker
 	Reset()
 }
+
+// Picker is a PickerOf items of any type
+type Picker = PickerOf[interface{}]
+
+// KeyFunc returns the identity of an item,
+// items with the same key are treated as the same one.
+// Pickers identify comparable items by themselves if not set,
+// so pointers like SubConns are never mixed up by their contents.
+type KeyFunc[T any] func(T) string
+
+// UpdaterOf is an optional interface of PickerOf,
+// which removes or reweights a single item
+// and keeps the runtime state of the other items intact
+type UpdaterOf[T any] interface {
+	// Remove the item, returns false if not found
+	Remove(T) bool
+	// UpdateWeight of the item, returns false if not found
+	UpdateWeight(T, float64) bool
+}
+
+// Updater is an UpdaterOf items of any type
+type Updater = UpdaterOf[interface{}]
+
+// InheritorOf is an optional interface of PickerOf,
+// which carries over the runtime state of the items still present
+// from the picker it replaces, like inflight requests and latency
+type InheritorOf[T any] interface {
+	// Inherit the state of the items added from the replaced picker
+	Inherit(PickerOf[T])
+}
+
+// Inheritor is an InheritorOf items of any type
+type Inheritor = InheritorOf[interface{}]
+
+// Inherit carries over the state from the replaced picker old to p if p is an InheritorOf,
+// otherwise p starts from scratch
+func Inherit[T any](p, old PickerOf[T]) {
+	if i, ok := p.(InheritorOf[T]); ok {
+		i.Inherit(old)
+	}
+}
+
+// TrackerOf is an optional interface of PickerOf,
+// which accounts an item picked elsewhere, like the one pinned by a session,
+// the same way as the items picked by Next, like inflight requests and latency
+type TrackerOf[T any] interface {
+	// Track the item as picked and returns its done function, returns false if not found
+	Track(T) (func(balancer.DoneInfo), bool)
+}
+
+// Tracker is a TrackerOf items of any type
+type Tracker = TrackerOf[interface{}]
+
+// NodeStatsOf is the state of an item in a picker,
+// fields not tracked by the picker are left zero
+type NodeStatsOf[T any] struct {
+	// Item, the picked item
+	Item T `json:"item"`
+	// Weight, the configured weight
+	Weight float64 `json:"weight"`
+	// EffectiveWeight, the weight recovering to Weight (smooth roundrobin)
+	EffectiveWeight float64 `json:"effective_weight,omitempty"`
+	// CurrentWeight, the current weight (smooth roundrobin)
+	CurrentWeight float64 `json:"current_weight,omitempty"`
+	// Inflight, the inflight requests (least loaded)
+	Inflight int64 `json:"inflight,omitempty"`
+	// EWMA, the peak EWMA of the latency (peak EWMA)
+	EWMA time.Duration `json:"ewma,omitempty"`
+	// LastSample, the time of the last latency sample, nil if none (peak EWMA)
+	LastSample *time.Time `json:"last_sample,omitempty"`
+	// Ejected, whether the item is ejected
+	Ejected bool `json:"ejected,omitempty"`
+	// Set, the set info the item is added with (Priority and Router)
+	Set *SetInfo `json:"set,omitempty"`
+}
+
+// NodeStats is a NodeStatsOf items of any type
+type NodeStats = NodeStatsOf[interface{}]
+
+// StatserOf is an optional interface of NexterOf,
+// which reports the state of every item for debugging
+type StatserOf[T any] interface {
+	// Stats returns the state of every item
+	Stats() []NodeStatsOf[T]
+}
+
+// Statser is a StatserOf items of any type
+type Statser = StatserOf[interface{}]
+
+// Stats returns the state of every item of n, or nil if n is not a StatserOf
+func Stats[T any](n NexterOf[T]) []NodeStatsOf[T] {
+	if s, ok := n.(StatserOf[T]); ok {
+		return s.Stats()
+	}
+
+	return nil
+}
+
+// ObserverOf is notified of the events of items for metrics,
+// it's called on the hot path so it must be fast and concurrency safe
+type ObserverOf[T any] interface {
+	// OnAdd is called when the item is added or its weight is updated
+	OnAdd(item T, weight float64)
+	// OnRemove is called when the item is removed
+	OnRemove(item T)
+	// OnPick is called when the item is picked
+	OnPick(item T)
+	// OnDone is called when the request to the picked item is done
+	OnDone(item T, latency time.Duration, err error)
+	// OnEject is called when the item is ejected or brought back
+	OnEject(item T, ejected bool)
+}
+
+// Observer is an ObserverOf items of any type
+type Observer = ObserverOf[interface{}]
+
+// PickerFactoryOf returns a new PickerOf,
+// for those who need more than one picker like Set and Aperture,
+// pickers replaced by a new one are closed if they are io.Closer
+type PickerFactoryOf[T any] func() PickerOf[T]
+
+// PickerFactory returns a new Picker
+type PickerFactory = PickerFactoryOf[interface{}]
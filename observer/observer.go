@@ -0,0 +1,238 @@
+package observer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"google.golang.org/grpc/balancer"
+)
+
+type picker[T any] struct {
+	picker   loadbalance.PickerOf[T]
+	observer loadbalance.ObserverOf[T]
+	id       func(T) interface{}
+	items    []T
+	refs     *refs
+	now      func() time.Time
+}
+
+// refs counts the items of all pickers built by the same factory,
+// an item is removed from the observer when it's gone from all of them
+type refs struct {
+	counts map[interface{}]int
+	mu     sync.Mutex
+}
+
+func newRefs() *refs {
+	return &refs{counts: make(map[interface{}]int)}
+}
+
+func (r *refs) add(id interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counts[id]++
+}
+
+// remove returns true if the item is gone from all pickers
+func (r *refs) remove(id interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counts[id]--
+	if r.counts[id] > 0 {
+		return false
+	}
+
+	delete(r.counts, id)
+	return true
+}
+
+// New returns a Picker decorates the picker,
+// which notifies the observer of every item added, removed, picked and done
+func New(picker loadbalance.Picker, observer loadbalance.Observer) loadbalance.Picker {
+	return NewOf(picker, observer)
+}
+
+// NewOf returns a PickerOf decorates the picker like New
+func NewOf[T any](p loadbalance.PickerOf[T], observer loadbalance.ObserverOf[T]) loadbalance.PickerOf[T] {
+	return NewWithKey(p, observer, nil)
+}
+
+// NewWithKey returns a PickerOf decorates the picker like New,
+// items are identified by key like the inner picker, or by the item itself if nil
+func NewWithKey[T any](p loadbalance.PickerOf[T], observer loadbalance.ObserverOf[T], key loadbalance.KeyFunc[T]) loadbalance.PickerOf[T] {
+	return newPicker(p, observer, internal.IdentityOf(key), newRefs())
+}
+
+func newPicker[T any](p loadbalance.PickerOf[T], observer loadbalance.ObserverOf[T], id func(T) interface{}, refs *refs) *picker[T] {
+	return &picker[T]{
+		picker:   p,
+		observer: observer,
+		id:       id,
+		items:    make([]T, 0),
+		refs:     refs,
+		now:      time.Now,
+	}
+}
+
+// Factory returns a PickerFactory decorates every picker built by factory,
+// which is used by Set and Aperture
+func Factory(factory loadbalance.PickerFactory, observer loadbalance.Observer) loadbalance.PickerFactory {
+	return FactoryOf(factory, observer)
+}
+
+// FactoryOf returns a PickerFactoryOf decorates every picker built by factory
+func FactoryOf[T any](factory loadbalance.PickerFactoryOf[T], observer loadbalance.ObserverOf[T]) loadbalance.PickerFactoryOf[T] {
+	return FactoryWithKey(factory, observer, nil)
+}
+
+// FactoryWithKey returns a PickerFactoryOf decorates every picker built by factory,
+// items are identified by key like the inner pickers, or by the item itself if nil.
+// Items are removed from the observer once they are gone from all pickers built,
+// which are dropped by Reset, Remove or Close.
+func FactoryWithKey[T any](factory loadbalance.PickerFactoryOf[T], observer loadbalance.ObserverOf[T], key loadbalance.KeyFunc[T]) loadbalance.PickerFactoryOf[T] {
+	id := internal.IdentityOf(key)
+	refs := newRefs()
+
+	return func() loadbalance.PickerOf[T] {
+		return newPicker(factory(), observer, id, refs)
+	}
+}
+
+// Add a weighted item.
+func (p *picker[T]) Add(item T, weight float64) {
+	p.items = append(p.items, item)
+	p.refs.add(p.id(item))
+	p.picker.Add(item, weight)
+	p.observer.OnAdd(item, weight)
+}
+
+// Reset this picker, all items are removed
+func (p *picker[T]) Reset() {
+	p.drop()
+	p.picker.Reset()
+}
+
+// Close drops all items like Reset but keeps the inner picker as it is,
+// which is called when the picker is replaced while picks are in flight
+func (p *picker[T]) Close() error {
+	p.drop()
+	return nil
+}
+
+// drop removes all items from the observer if gone from all pickers
+func (p *picker[T]) drop() {
+	for _, item := range p.items {
+		if p.refs.remove(p.id(item)) {
+			p.observer.OnRemove(item)
+		}
+	}
+
+	p.items = p.items[:0]
+}
+
+// Remove the item if the inner picker is an Updater
+func (p *picker[T]) Remove(item T) bool {
+	updater, ok := p.picker.(loadbalance.UpdaterOf[T])
+	if !ok || !updater.Remove(item) {
+		return false
+	}
+
+	id := p.id(item)
+	gone := false
+	items := p.items[:0]
+	for _, i := range p.items {
+		if p.id(i) != id {
+			items = append(items, i)
+		} else if p.refs.remove(id) {
+			gone = true
+		}
+	}
+	p.items = items
+
+	if gone {
+		p.observer.OnRemove(item)
+	}
+
+	return true
+}
+
+// UpdateWeight of the item if the inner picker is an Updater
+func (p *picker[T]) UpdateWeight(item T, weight float64) bool {
+	updater, ok := p.picker.(loadbalance.UpdaterOf[T])
+	if !ok || !updater.UpdateWeight(item, weight) {
+		return false
+	}
+
+	p.observer.OnAdd(item, weight)
+
+	return true
+}
+
+// Track the item picked elsewhere, which is observed like the ones picked by Next,
+// the item is assumed present if the inner picker is not a Tracker
+func (p *picker[T]) Track(item T) (func(balancer.DoneInfo), bool) {
+	done := internal.EmptyDoneFunc
+	if tracker, ok := p.picker.(loadbalance.TrackerOf[T]); ok {
+		if done, ok = tracker.Track(item); !ok {
+			return done, false
+		}
+	}
+
+	return p.observe(item, done), true
+}
+
+// Inherit the state of the inner picker of the replaced one
+func (p *picker[T]) Inherit(old loadbalance.PickerOf[T]) {
+	if o, ok := old.(*picker[T]); ok {
+		old = o.picker
+	}
+
+	loadbalance.Inherit(p.picker, old)
+}
+
+// Stats returns the state of every item of the inner picker
+func (p *picker[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	return loadbalance.Stats[T](p.picker)
+}
+
+// Next returns the next selected item.
+func (p *picker[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := p.NextErr()
+	return item, done
+}
+
+// NextErr returns the next selected item, nothing is observed on error
+func (p *picker[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	item, done, err := loadbalance.NextErr[T](p.picker)
+	if err != nil {
+		return item, done, err
+	}
+
+	return item, p.observe(item, done), nil
+}
+
+// NextCandidates returns the next selected item and the candidates compared by the inner picker,
+// nothing is observed on error
+func (p *picker[T]) NextCandidates() (T, func(balancer.DoneInfo), []loadbalance.NodeStatsOf[T], error) {
+	item, done, candidates, err := loadbalance.NextCandidates[T](p.picker)
+	if err != nil {
+		return item, done, candidates, err
+	}
+
+	return item, p.observe(item, done), candidates, nil
+}
+
+// observe notifies the observer of the picked item, and of its latency when done
+func (p *picker[T]) observe(item T, done func(balancer.DoneInfo)) func(balancer.DoneInfo) {
+	begin := p.now()
+	p.observer.OnPick(item)
+
+	return func(info balancer.DoneInfo) {
+		done(info)
+		p.observer.OnDone(item, p.now().Sub(begin), info.Err)
+	}
+}
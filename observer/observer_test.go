@@ -0,0 +1,169 @@
+package observer_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/aperture"
+	"github.com/hnlq715/go-loadbalance/observer"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) OnAdd(item interface{}, weight float64) {
+	r.events = append(r.events, fmt.Sprintf("add %v %v", item, weight))
+}
+
+func (r *recorder) OnRemove(item interface{}) {
+	r.events = append(r.events, fmt.Sprintf("remove %v", item))
+}
+
+func (r *recorder) OnPick(item interface{}) {
+	r.events = append(r.events, fmt.Sprintf("pick %v", item))
+}
+
+func (r *recorder) OnDone(item interface{}, latency time.Duration, err error) {
+	r.events = append(r.events, fmt.Sprintf("done %v %v", item, err))
+}
+
+func (r *recorder) OnEject(item interface{}, ejected bool) {
+	r.events = append(r.events, fmt.Sprintf("eject %v %v", item, ejected))
+}
+
+func TestObserver(t *testing.T) {
+	r := &recorder{}
+	p := observer.New(roundrobin.NewSmoothRoundrobin(), r)
+
+	item, _ := p.Next()
+	assert.Nil(t, item)
+
+	p.Add(1, 1)
+	p.Add(2, 1)
+
+	item, done := p.Next()
+	done(balancer.DoneInfo{Err: errors.New("timeout")})
+	assert.Equal(t, 1, item)
+
+	updater := p.(loadbalance.Updater)
+	assert.True(t, updater.UpdateWeight(2, 3))
+	assert.True(t, updater.Remove(1))
+	assert.False(t, updater.Remove(1))
+
+	tracker := p.(loadbalance.Tracker)
+	_, ok := tracker.Track(1)
+	assert.False(t, ok)
+	done, ok = tracker.Track(2)
+	assert.True(t, ok)
+	done(balancer.DoneInfo{})
+	p.Reset()
+
+	assert.Equal(t, []string{
+		"add 1 1",
+		"add 2 1",
+		"pick 1",
+		"done 1 timeout",
+		"add 2 3",
+		"remove 1",
+		"pick 2",
+		"done 2 <nil>",
+		"remove 2",
+	}, r.events)
+}
+
+func TestFactory(t *testing.T) {
+	r := &recorder{}
+	a := aperture.New(observer.Factory(roundrobin.NewSmoothRoundrobin, r), aperture.WithLogicalAperture(1))
+	a.Update("0", []string{"0", "1"}, []interface{}{1, 2})
+
+	item, done := a.Next()
+	done(balancer.DoneInfo{})
+
+	assert.Equal(t, 1, item)
+	assert.Equal(t, []string{"add 1 1", "pick 1", "done 1 <nil>"}, r.events)
+
+	// only the departed peer is removed when the aperture is rebuilt
+	r.events = nil
+	a.SetRemotePeers([]interface{}{3, 1})
+	assert.Equal(t, []string{"add 3 1", "remove 1"}, r.events)
+
+	r.events = nil
+	a.SetLogicalAperture(2)
+	a.SetRemotePeers(nil)
+	assert.Equal(t, []string{"add 3 1", "add 1 1", "remove 3", "remove 1"}, r.events)
+}
+
+func TestInherit(t *testing.T) {
+	r := &recorder{}
+	factory := observer.Factory(p2c.NewLeastLoaded, r)
+	old := factory()
+	old.Add(1, 1)
+	_, done := old.Next()
+
+	// the inner pickers inherit through the observers
+	p := factory()
+	p.Add(1, 1)
+	loadbalance.Inherit(p, old)
+	assert.Equal(t, int64(1), loadbalance.Stats[interface{}](p)[0].Inflight)
+	done(balancer.DoneInfo{})
+	assert.Equal(t, int64(0), loadbalance.Stats[interface{}](p)[0].Inflight)
+}
+
+func TestCandidates(t *testing.T) {
+	r := &recorder{}
+	p := observer.New(p2c.NewLeastLoaded(), r)
+	p.Add(1, 1)
+
+	// the candidates of the inner picker are passed through
+	item, done, candidates, err := loadbalance.NextCandidates[interface{}](p)
+	assert.NoError(t, err)
+	assert.Equal(t, []loadbalance.NodeStats{{Item: 1, Weight: 1}}, candidates)
+	done(balancer.DoneInfo{})
+	assert.Equal(t, 1, item)
+	assert.Equal(t, []string{"add 1 1", "pick 1", "done 1 <nil>"}, r.events)
+}
+
+func TestKey(t *testing.T) {
+	type addr struct {
+		host string
+		zone string
+	}
+
+	r := &recorder{}
+	key := func(a addr) string { return a.host }
+	p := observer.NewWithKey[addr](roundrobin.NewSmoothRoundrobinWithKey(key), observerOf[addr]{r}, key)
+	p.Add(addr{host: "10.0.0.1", zone: "bj-a"}, 1)
+	p.Add(addr{host: "10.0.0.2", zone: "bj-b"}, 1)
+
+	// zone is not a part of the identity
+	assert.True(t, p.(loadbalance.UpdaterOf[addr]).Remove(addr{host: "10.0.0.1"}))
+	p.Reset()
+
+	assert.Equal(t, []string{
+		"add {10.0.0.1 bj-a} 1",
+		"add {10.0.0.2 bj-b} 1",
+		"remove {10.0.0.1 }",
+		"remove {10.0.0.2 bj-b}",
+	}, r.events)
+}
+
+// observerOf adapts the recorder to items of type T
+type observerOf[T any] struct {
+	*recorder
+}
+
+func (o observerOf[T]) OnAdd(item T, weight float64) { o.recorder.OnAdd(item, weight) }
+func (o observerOf[T]) OnRemove(item T)              { o.recorder.OnRemove(item) }
+func (o observerOf[T]) OnPick(item T)                { o.recorder.OnPick(item) }
+func (o observerOf[T]) OnDone(item T, latency time.Duration, err error) {
+	o.recorder.OnDone(item, latency, err)
+}
+func (o observerOf[T]) OnEject(item T, ejected bool) { o.recorder.OnEject(item, ejected) }
@@ -0,0 +1,255 @@
+package prometheus
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "loadbalance"
+
+	// defaultTau is the same as the peak EWMA picker
+	defaultTau = 10 * time.Second
+)
+
+var (
+	picksDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "picks_total"),
+		"Number of times the item is picked.",
+		[]string{"picker", "item"}, nil,
+	)
+	errorsDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "errors_total"),
+		"Number of requests to the item done with error.",
+		[]string{"picker", "item"}, nil,
+	)
+	inflightDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "inflight"),
+		"Number of inflight requests to the item.",
+		[]string{"picker", "item"}, nil,
+	)
+	latencyDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "latency_ewma_seconds"),
+		"Exponentially weighted moving average of the latency of the item.",
+		[]string{"picker", "item"}, nil,
+	)
+	weightDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "weight"),
+		"Configured weight of the item.",
+		[]string{"picker", "item"}, nil,
+	)
+	ejectedDesc = prom.NewDesc(
+		prom.BuildFQName(namespace, "", "ejected"),
+		"Whether the item is ejected.",
+		[]string{"picker", "item"}, nil,
+	)
+)
+
+// node is updated atomically, so picks never wait for each other
+type node struct {
+	label    string
+	picks    uint64
+	errors   uint64
+	inflight int64
+	latency  uint64 // float64 bits of seconds
+	stamp    int64  // unix nano of the last observed latency
+	weight   uint64 // float64 bits
+	ejected  int32
+}
+
+// ewma returns the latency EWMA in seconds
+func (n *node) ewma() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&n.latency))
+}
+
+// CollectorOf is a prometheus.Collector and an ObserverOf,
+// which exports the per item metrics of the observed pickers.
+// Removed items are not exported.
+type CollectorOf[T any] struct {
+	name  string
+	tau   time.Duration
+	now   func() time.Time
+	id    func(T) interface{}
+	label func(T) string
+	nodes map[interface{}]*node
+	// mu guards nodes only, which is read locked by picks
+	mu sync.RWMutex
+}
+
+// Collector is a CollectorOf items of any type
+type Collector = CollectorOf[interface{}]
+
+type options struct {
+	tau time.Duration
+}
+
+// Option configures the Collector
+type Option func(*options)
+
+// WithTau sets the decay time of the latency EWMA
+func WithTau(tau time.Duration) Option {
+	return func(o *options) {
+		if tau > 0 {
+			o.tau = tau
+		}
+	}
+}
+
+// NewCollector returns a Collector, name is the picker label
+func NewCollector(name string, opts ...Option) *Collector {
+	return NewCollectorOf[interface{}](name, opts...)
+}
+
+// NewCollectorOf returns a CollectorOf, name is the picker label
+func NewCollectorOf[T any](name string, opts ...Option) *CollectorOf[T] {
+	return NewCollectorWithKey[T](name, nil, opts...)
+}
+
+// NewCollectorWithKey returns a CollectorOf, name is the picker label,
+// items are identified and labeled by key, like the address of SubConns.
+// If nil, items are identified by themselves and labeled by their String,
+// pointers without String are labeled by their address.
+func NewCollectorWithKey[T any](name string, key loadbalance.KeyFunc[T], opts ...Option) *CollectorOf[T] {
+	o := options{tau: defaultTau}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	label := func(item T) string { return internal.Name(item) }
+	if key != nil {
+		label = key
+	}
+
+	return &CollectorOf[T]{
+		name:  name,
+		tau:   o.tau,
+		now:   time.Now,
+		id:    internal.IdentityOf(key),
+		label: label,
+		nodes: make(map[interface{}]*node),
+	}
+}
+
+var (
+	_ prom.Collector       = (*Collector)(nil)
+	_ loadbalance.Observer = (*Collector)(nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *CollectorOf[T]) Describe(ch chan<- *prom.Desc) {
+	ch <- picksDesc
+	ch <- errorsDesc
+	ch <- inflightDesc
+	ch <- latencyDesc
+	ch <- weightDesc
+	ch <- ejectedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *CollectorOf[T]) Collect(ch chan<- prom.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, n := range c.nodes {
+		picks := atomic.LoadUint64(&n.picks)
+		errors := atomic.LoadUint64(&n.errors)
+		inflight := atomic.LoadInt64(&n.inflight)
+		weight := math.Float64frombits(atomic.LoadUint64(&n.weight))
+		ejected := float64(atomic.LoadInt32(&n.ejected))
+
+		ch <- prom.MustNewConstMetric(picksDesc, prom.CounterValue, float64(picks), c.name, n.label)
+		ch <- prom.MustNewConstMetric(errorsDesc, prom.CounterValue, float64(errors), c.name, n.label)
+		ch <- prom.MustNewConstMetric(inflightDesc, prom.GaugeValue, float64(inflight), c.name, n.label)
+		ch <- prom.MustNewConstMetric(latencyDesc, prom.GaugeValue, n.ewma(), c.name, n.label)
+		ch <- prom.MustNewConstMetric(weightDesc, prom.GaugeValue, weight, c.name, n.label)
+		ch <- prom.MustNewConstMetric(ejectedDesc, prom.GaugeValue, ejected, c.name, n.label)
+	}
+}
+
+// OnAdd implements Observer
+func (c *CollectorOf[T]) OnAdd(item T, weight float64) {
+	atomic.StoreUint64(&c.node(item).weight, math.Float64bits(weight))
+}
+
+// OnRemove implements Observer
+func (c *CollectorOf[T]) OnRemove(item T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.nodes, c.id(item))
+}
+
+// OnPick implements Observer
+func (c *CollectorOf[T]) OnPick(item T) {
+	n := c.node(item)
+	atomic.AddUint64(&n.picks, 1)
+	atomic.AddInt64(&n.inflight, 1)
+}
+
+// OnDone implements Observer, the latency is observed as the peak EWMA picker
+func (c *CollectorOf[T]) OnDone(item T, latency time.Duration, err error) {
+	c.mu.RLock()
+	n, ok := c.nodes[c.id(item)]
+	c.mu.RUnlock()
+
+	// the item is removed before done
+	if !ok {
+		return
+	}
+
+	atomic.AddInt64(&n.inflight, -1)
+	if err != nil {
+		atomic.AddUint64(&n.errors, 1)
+	}
+
+	now := c.now().UnixNano()
+	td := now - atomic.SwapInt64(&n.stamp, now)
+	if td < 0 {
+		td = 0
+	}
+
+	w := math.Exp(float64(-td) / float64(c.tau))
+	rtt, ewma := latency.Seconds(), n.ewma()
+	if rtt < ewma {
+		rtt = ewma*w + rtt*(1-w)
+	}
+	atomic.StoreUint64(&n.latency, math.Float64bits(rtt))
+}
+
+// OnEject implements Observer
+func (c *CollectorOf[T]) OnEject(item T, ejected bool) {
+	value := int32(0)
+	if ejected {
+		value = 1
+	}
+
+	atomic.StoreInt32(&c.node(item).ejected, value)
+}
+
+// node returns the node of the item, creates it if not exists
+func (c *CollectorOf[T]) node(item T) *node {
+	id := c.id(item)
+
+	c.mu.RLock()
+	n, ok := c.nodes[id]
+	c.mu.RUnlock()
+	if ok {
+		return n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n, ok = c.nodes[id]; !ok {
+		n = &node{label: c.label(item)}
+		c.nodes[id] = n
+	}
+
+	return n
+}
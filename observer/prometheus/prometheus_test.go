@@ -0,0 +1,190 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/aperture"
+	"github.com/hnlq715/go-loadbalance/observer"
+	"github.com/hnlq715/go-loadbalance/priority"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+func TestCollector(t *testing.T) {
+	t.Run("picks", func(t *testing.T) {
+		c := NewCollector("backend")
+		p := observer.New(roundrobin.NewSmoothRoundrobin(), c)
+		p.Add("10.0.0.1:80", 2)
+		p.Add("10.0.0.2:80", 1)
+
+		_, done := p.Next()
+		done(balancer.DoneInfo{})
+		_, done = p.Next()
+		done(balancer.DoneInfo{Err: errors.New("timeout")})
+		p.Next()
+
+		expected := `
+# HELP loadbalance_errors_total Number of requests to the item done with error.
+# TYPE loadbalance_errors_total counter
+loadbalance_errors_total{item="10.0.0.1:80",picker="backend"} 0
+loadbalance_errors_total{item="10.0.0.2:80",picker="backend"} 1
+# HELP loadbalance_inflight Number of inflight requests to the item.
+# TYPE loadbalance_inflight gauge
+loadbalance_inflight{item="10.0.0.1:80",picker="backend"} 1
+loadbalance_inflight{item="10.0.0.2:80",picker="backend"} 0
+# HELP loadbalance_picks_total Number of times the item is picked.
+# TYPE loadbalance_picks_total counter
+loadbalance_picks_total{item="10.0.0.1:80",picker="backend"} 2
+loadbalance_picks_total{item="10.0.0.2:80",picker="backend"} 1
+# HELP loadbalance_weight Configured weight of the item.
+# TYPE loadbalance_weight gauge
+loadbalance_weight{item="10.0.0.1:80",picker="backend"} 2
+loadbalance_weight{item="10.0.0.2:80",picker="backend"} 1
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+			"loadbalance_picks_total", "loadbalance_errors_total", "loadbalance_inflight", "loadbalance_weight")
+		assert.NoError(t, err)
+
+		// removed items are not exported
+		assert.True(t, p.(loadbalance.Updater).Remove("10.0.0.2:80"))
+		assert.Equal(t, 6, testutil.CollectAndCount(c))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		now := time.Now()
+		c := NewCollectorOf[string]("backend", WithTau(time.Second))
+		c.now = func() time.Time { return now }
+
+		c.OnAdd("10.0.0.1:80", 1)
+		c.OnPick("10.0.0.1:80")
+		c.OnDone("10.0.0.1:80", 100*time.Millisecond, nil)
+		assert.InDelta(t, 0.1, c.nodes["10.0.0.1:80"].ewma(), 1e-9)
+
+		now = now.Add(time.Second)
+		c.OnPick("10.0.0.1:80")
+		c.OnDone("10.0.0.1:80", 0, nil)
+		assert.InDelta(t, 0.1*math.Exp(-1), c.nodes["10.0.0.1:80"].ewma(), 1e-9)
+
+		// done after removed is ignored
+		c.OnPick("10.0.0.1:80")
+		c.OnRemove("10.0.0.1:80")
+		c.OnDone("10.0.0.1:80", 0, nil)
+		assert.Equal(t, 0, testutil.CollectAndCount(c))
+	})
+
+	t.Run("ejected", func(t *testing.T) {
+		c := NewCollector("backend")
+		info := loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+		p := priority.New(info)
+		p.SetObserver(c)
+		p.Add("10.0.0.1:80", 1, info)
+		p.SetHealthy("10.0.0.1:80", false)
+
+		expected := `
+# HELP loadbalance_ejected Whether the item is ejected.
+# TYPE loadbalance_ejected gauge
+loadbalance_ejected{item="10.0.0.1:80",picker="backend"} 1
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "loadbalance_ejected")
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		c := NewCollector("backend")
+		c.OnAdd("10.0.0.1:80", 1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					item := fmt.Sprintf("10.0.0.%d:80", j%4)
+					c.OnPick(item)
+					c.OnDone(item, time.Millisecond, nil)
+				}
+				testutil.CollectAndCount(c)
+			}(i)
+		}
+		wg.Wait()
+
+		expected := `
+# HELP loadbalance_picks_total Number of times the item is picked.
+# TYPE loadbalance_picks_total counter
+loadbalance_picks_total{item="10.0.0.0:80",picker="backend"} 200
+loadbalance_picks_total{item="10.0.0.1:80",picker="backend"} 200
+loadbalance_picks_total{item="10.0.0.2:80",picker="backend"} 200
+loadbalance_picks_total{item="10.0.0.3:80",picker="backend"} 200
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "loadbalance_picks_total")
+		assert.NoError(t, err)
+	})
+
+	t.Run("key", func(t *testing.T) {
+		type conn struct {
+			addr  string
+			state int
+		}
+
+		c := NewCollectorWithKey("backend", func(c *conn) string { return c.addr })
+		p := observer.NewWithKey[*conn](roundrobin.NewSmoothRoundrobinOf[*conn](), c, func(c *conn) string { return c.addr })
+		a := &conn{addr: "10.0.0.1:80"}
+		p.Add(a, 1)
+
+		// the state is not a part of the series
+		a.state = 1
+		_, done := p.Next()
+		done(balancer.DoneInfo{})
+
+		expected := `
+# HELP loadbalance_picks_total Number of times the item is picked.
+# TYPE loadbalance_picks_total counter
+loadbalance_picks_total{item="10.0.0.1:80",picker="backend"} 1
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "loadbalance_picks_total")
+		assert.NoError(t, err)
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		type conn struct {
+			addr string
+		}
+
+		// pointers are labeled by address without reading their contents
+		c := NewCollectorOf[*conn]("backend")
+		a, b := &conn{addr: "10.0.0.1:80"}, &conn{addr: "10.0.0.1:80"}
+		c.OnAdd(a, 1)
+		c.OnAdd(b, 1)
+		assert.Equal(t, 12, testutil.CollectAndCount(c))
+		assert.NotContains(t, c.nodes[a].label, "10.0.0.1")
+	})
+
+	t.Run("aperture", func(t *testing.T) {
+		c := NewCollector("backend")
+		a := aperture.New(observer.Factory(roundrobin.NewSmoothRoundrobin, c), aperture.WithLogicalAperture(1))
+		a.Update("0", []string{"0", "1"}, []interface{}{"10.0.0.1:80", "10.0.0.2:80"})
+		assert.Equal(t, 6, testutil.CollectAndCount(c))
+
+		// departed peers are not exported after the aperture is rebuilt
+		a.SetRemotePeers([]interface{}{"10.0.0.3:80", "10.0.0.2:80"})
+		expected := `
+# HELP loadbalance_weight Configured weight of the item.
+# TYPE loadbalance_weight gauge
+loadbalance_weight{item="10.0.0.3:80",picker="backend"} 1
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "loadbalance_weight")
+		assert.NoError(t, err)
+
+		a.SetRemotePeers(nil)
+		assert.Equal(t, 0, testutil.CollectAndCount(c))
+	})
+}
@@ -0,0 +1,211 @@
+package otelpicker
+
+import (
+	"context"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/grpcpicker"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"github.com/hnlq715/go-loadbalance/set"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/balancer"
+)
+
+const (
+	instrumentationName = "github.com/hnlq715/go-loadbalance/otelpicker"
+
+	// pickEvent is the span event added on every pick
+	pickEvent = "loadbalance.pick"
+)
+
+// attribute keys of the pick event and metrics
+const (
+	AlgorithmKey = attribute.Key("loadbalance.algorithm")
+	EndpointKey  = attribute.Key("loadbalance.endpoint")
+	SetNameKey   = attribute.Key("loadbalance.set.name")
+	SetUnitKey   = attribute.Key("loadbalance.set.unit")
+	ErrorKey     = attribute.Key("loadbalance.error")
+
+	// the candidates compared for the pick, the picked one comes first
+	CandidatesKey        = attribute.Key("loadbalance.candidates")
+	CandidateWeightKey   = attribute.Key("loadbalance.candidate.weight")
+	CandidateInflightKey = attribute.Key("loadbalance.candidate.inflight")
+	CandidateLatencyKey  = attribute.Key("loadbalance.candidate.latency_ewma")
+)
+
+type options struct {
+	meterProvider metric.MeterProvider
+	endpoint      func(balancer.SubConn) string
+	attributes    func(balancer.PickInfo, balancer.SubConn) []attribute.KeyValue
+}
+
+// Option configures the picker
+type Option func(*options)
+
+// WithMeterProvider sets the MeterProvider, the global one is used by default
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = provider
+	}
+}
+
+// WithEndpoint sets the name of the picked SubConn, like its address,
+// the SubConn's String or pointer address by default
+func WithEndpoint(endpoint func(balancer.SubConn) string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithAttributes adds extra attributes of the picked SubConn to the pick event
+func WithAttributes(attributes func(balancer.PickInfo, balancer.SubConn) []attribute.KeyValue) Option {
+	return func(o *options) {
+		o.attributes = attributes
+	}
+}
+
+type picker struct {
+	picker    balancer.Picker
+	algorithm attribute.KeyValue
+	options
+
+	pickDuration    metric.Float64Histogram
+	picks           metric.Int64Counter
+	requestDuration metric.Float64Histogram
+	requests        metric.Int64Counter
+}
+
+// New returns a gRPC balancer.Picker decorates the picker,
+// which adds a pick event to the span in the RPC context,
+// with the candidates compared if the picker is a grpcpicker.CandidatesPicker, like p2c,
+// and records the pick and request latency and errors by the algorithm
+func New(p balancer.Picker, algorithm string, opts ...Option) balancer.Picker {
+	o := options{
+		meterProvider: otel.GetMeterProvider(),
+		endpoint: func(sc balancer.SubConn) string {
+			return internal.Name(sc)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meter := o.meterProvider.Meter(instrumentationName)
+	pp := &picker{
+		picker:    p,
+		algorithm: AlgorithmKey.String(algorithm),
+		options:   o,
+	}
+
+	var err error
+	if pp.pickDuration, err = meter.Float64Histogram("loadbalance.pick.duration",
+		metric.WithUnit("s"), metric.WithDescription("Time spent to pick a SubConn.")); err != nil {
+		otel.Handle(err)
+	}
+	if pp.picks, err = meter.Int64Counter("loadbalance.picks",
+		metric.WithDescription("Number of picks, by whether the pick failed.")); err != nil {
+		otel.Handle(err)
+	}
+	if pp.requestDuration, err = meter.Float64Histogram("loadbalance.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Latency of the requests to the picked SubConns.")); err != nil {
+		otel.Handle(err)
+	}
+	if pp.requests, err = meter.Int64Counter("loadbalance.requests",
+		metric.WithDescription("Number of requests to the picked SubConns, by whether the request failed.")); err != nil {
+		otel.Handle(err)
+	}
+
+	return pp
+}
+
+// Pick returns the SubConn picked by the inner picker
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	var (
+		result     balancer.PickResult
+		candidates []loadbalance.NodeStatsOf[balancer.SubConn]
+		err        error
+	)
+	begin := time.Now()
+	if cp, ok := p.picker.(grpcpicker.CandidatesPicker); ok {
+		result, candidates, err = cp.PickCandidates(info)
+	} else {
+		result, err = p.picker.Pick(info)
+	}
+	elapsed := time.Since(begin)
+
+	ctx := info.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := trace.SpanFromContext(ctx)
+
+	attrs := []attribute.KeyValue{p.algorithm}
+	if requested, ok := set.FromContext(ctx); ok {
+		attrs = append(attrs, SetNameKey.String(requested.Name), SetUnitKey.String(requested.UnitName))
+	}
+
+	p.record(ctx, p.pickDuration, p.picks, elapsed, err)
+
+	if err != nil {
+		span.AddEvent(pickEvent, trace.WithAttributes(append(attrs, ErrorKey.Bool(true))...))
+		return result, err
+	}
+
+	attrs = append(attrs, EndpointKey.String(p.endpoint(result.SubConn)))
+	if len(candidates) > 0 {
+		attrs = append(attrs, p.candidates(candidates)...)
+	}
+	if p.attributes != nil {
+		attrs = append(attrs, p.attributes(info, result.SubConn)...)
+	}
+	span.AddEvent(pickEvent, trace.WithAttributes(attrs...))
+
+	done := result.Done
+	picked := time.Now()
+	result.Done = func(doneInfo balancer.DoneInfo) {
+		if done != nil {
+			done(doneInfo)
+		}
+
+		p.record(ctx, p.requestDuration, p.requests, time.Since(picked), doneInfo.Err)
+	}
+
+	return result, nil
+}
+
+// candidates returns the attributes of the candidates in order
+func (p *picker) candidates(candidates []loadbalance.NodeStatsOf[balancer.SubConn]) []attribute.KeyValue {
+	endpoints := make([]string, 0, len(candidates))
+	weights := make([]float64, 0, len(candidates))
+	inflight := make([]int64, 0, len(candidates))
+	latency := make([]float64, 0, len(candidates))
+	for _, c := range candidates {
+		endpoints = append(endpoints, p.endpoint(c.Item))
+		weights = append(weights, c.Weight)
+		inflight = append(inflight, c.Inflight)
+		latency = append(latency, c.EWMA.Seconds())
+	}
+
+	return []attribute.KeyValue{
+		CandidatesKey.StringSlice(endpoints),
+		CandidateWeightKey.Float64Slice(weights),
+		CandidateInflightKey.Int64Slice(inflight),
+		CandidateLatencyKey.Float64Slice(latency),
+	}
+}
+
+// record records the latency and count by the algorithm and whether it failed,
+// instruments failed to create are skipped
+func (p *picker) record(ctx context.Context, duration metric.Float64Histogram, count metric.Int64Counter, elapsed time.Duration, err error) {
+	attrs := metric.WithAttributes(p.algorithm, ErrorKey.Bool(err != nil))
+	if duration != nil {
+		duration.Record(ctx, elapsed.Seconds(), attrs)
+	}
+	if count != nil {
+		count.Add(ctx, 1, attrs)
+	}
+}
@@ -0,0 +1,162 @@
+package otelpicker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/grpcpicker"
+	"github.com/hnlq715/go-loadbalance/otelpicker"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/set"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/resolver"
+)
+
+type subConn struct {
+	addr string
+}
+
+func (sc *subConn) UpdateAddresses([]resolver.Address) {}
+
+func (sc *subConn) Connect() {}
+
+func TestPicker(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	rr := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+	p := otelpicker.New(grpcpicker.New(rr), "roundrobin",
+		otelpicker.WithMeterProvider(meterProvider),
+		otelpicker.WithEndpoint(func(sc balancer.SubConn) string {
+			return sc.(*subConn).addr
+		}),
+		otelpicker.WithAttributes(func(_ balancer.PickInfo, sc balancer.SubConn) []attribute.KeyValue {
+			return []attribute.KeyValue{attribute.String("net.peer.name", sc.(*subConn).addr)}
+		}),
+	)
+
+	// no SubConn yet
+	ctx, span := tracer.Start(context.Background(), "rpc")
+	_, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+	span.End()
+
+	rr.Add(&subConn{addr: "10.0.0.1:80"}, 1)
+
+	ctx = set.NewContext(context.Background(), loadbalance.SetInfo{Name: "app", UnitName: "01"})
+	ctx, span = tracer.Start(ctx, "rpc")
+	result, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.NoError(t, err)
+	result.Done(balancer.DoneInfo{Err: errors.New("timeout")})
+	span.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	failed := spans[0].Events()
+	assert.Len(t, failed, 1)
+	assert.Equal(t, "loadbalance.pick", failed[0].Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		otelpicker.AlgorithmKey.String("roundrobin"),
+		otelpicker.ErrorKey.Bool(true),
+	}, failed[0].Attributes)
+
+	picked := spans[1].Events()
+	assert.Len(t, picked, 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		otelpicker.AlgorithmKey.String("roundrobin"),
+		otelpicker.SetNameKey.String("app"),
+		otelpicker.SetUnitKey.String("01"),
+		otelpicker.EndpointKey.String("10.0.0.1:80"),
+		attribute.String("net.peer.name", "10.0.0.1:80"),
+	}, picked[0].Attributes)
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	assert.Len(t, rm.ScopeMetrics, 1)
+
+	counts := make(map[string]map[bool]int64)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		counts[m.Name] = make(map[bool]int64)
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				failed, _ := dp.Attributes.Value(otelpicker.ErrorKey)
+				counts[m.Name][failed.AsBool()] = dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				failed, _ := dp.Attributes.Value(otelpicker.ErrorKey)
+				counts[m.Name][failed.AsBool()] = int64(dp.Count)
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]map[bool]int64{
+		"loadbalance.pick.duration":    {false: 1, true: 1},
+		"loadbalance.picks":            {false: 1, true: 1},
+		"loadbalance.request.duration": {true: 1},
+		"loadbalance.requests":         {true: 1},
+	}, counts)
+}
+
+func TestEndpoint(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	rr := roundrobin.NewSmoothRoundrobinOf[balancer.SubConn]()
+	p := otelpicker.New(grpcpicker.New(rr), "roundrobin")
+	sc := &subConn{addr: "10.0.0.1:80"}
+	rr.Add(sc, 1)
+
+	ctx, span := tracer.Start(context.Background(), "rpc")
+	_, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.NoError(t, err)
+	span.End()
+
+	// the SubConn is named by its pointer address without reading its fields
+	events := recorder.Ended()[0].Events()
+	assert.Contains(t, events[0].Attributes, otelpicker.EndpointKey.String(fmt.Sprintf("%p", sc)))
+}
+
+func TestCandidates(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ll := p2c.NewLeastLoadedOf[balancer.SubConn]()
+	a, b := &subConn{addr: "10.0.0.1:80"}, &subConn{addr: "10.0.0.2:80"}
+	ll.Add(a, 1)
+	ll.Add(b, 2)
+	ll.(loadbalance.TrackerOf[balancer.SubConn]).Track(a)
+
+	p := otelpicker.New(grpcpicker.New(ll), "p2c",
+		otelpicker.WithEndpoint(func(sc balancer.SubConn) string {
+			return sc.(*subConn).addr
+		}),
+	)
+
+	ctx, span := tracer.Start(context.Background(), "rpc")
+	result, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+	assert.NoError(t, err)
+	assert.Equal(t, b, result.SubConn)
+	span.End()
+
+	// both candidates are recorded, the picked one comes first
+	attrs := recorder.Ended()[0].Events()[0].Attributes
+	assert.Contains(t, attrs, otelpicker.CandidatesKey.StringSlice([]string{"10.0.0.2:80", "10.0.0.1:80"}))
+	assert.Contains(t, attrs, otelpicker.CandidateWeightKey.Float64Slice([]float64{2, 1}))
+	assert.Contains(t, attrs, otelpicker.CandidateInflightKey.Int64Slice([]int64{0, 1}))
+	assert.Contains(t, attrs, otelpicker.CandidateLatencyKey.Float64Slice([]float64{0, 0}))
+}
@@ -11,39 +11,173 @@ import (
 	"google.golang.org/grpc/balancer"
 )
 
-type leastLoadedNode struct {
-	item     interface{}
-	inflight int64
+type leastLoadedNode[T any] struct {
+	item     T
+	id       interface{}
+	inflight *int64
 	weight   float64
 }
 
-type leastLoaded struct {
-	items []*leastLoadedNode
+// stats returns the weight and inflight of the node
+func (n *leastLoadedNode[T]) stats() loadbalance.NodeStatsOf[T] {
+	return loadbalance.NodeStatsOf[T]{
+		Item:     n.item,
+		Weight:   n.weight,
+		Inflight: atomic.LoadInt64(n.inflight),
+	}
+}
+
+// pick increases the inflight of the node until done
+func (n *leastLoadedNode[T]) pick() func(balancer.DoneInfo) {
+	atomic.AddInt64(n.inflight, 1)
+
+	return func(balancer.DoneInfo) {
+		atomic.AddInt64(n.inflight, -1)
+	}
+}
+
+type leastLoaded[T any] struct {
+	items []*leastLoadedNode[T]
+	id    func(T) interface{}
 	mu    sync.Mutex
 	rand  *rand.Rand
 }
 
 func NewLeastLoaded() loadbalance.Picker {
-	return &leastLoaded{
-		items: make([]*leastLoadedNode, 0),
+	return NewLeastLoadedOf[interface{}]()
+}
+
+// NewLeastLoadedOf returns a least loaded picker of items of type T
+func NewLeastLoadedOf[T any]() loadbalance.PickerOf[T] {
+	return NewLeastLoadedWithKey[T](nil)
+}
+
+// NewLeastLoadedWithKey returns a least loaded picker of items of type T,
+// which are identified by key in Remove, UpdateWeight and Track,
+// or by the item itself if nil
+func NewLeastLoadedWithKey[T any](key loadbalance.KeyFunc[T]) loadbalance.PickerOf[T] {
+	return &leastLoaded[T]{
+		items: make([]*leastLoadedNode[T], 0),
+		id:    internal.IdentityOf(key),
 		rand:  rand.New(rand.NewSource(time.Now().Unix())),
 	}
 }
 
-func (p *leastLoaded) Add(item interface{}, weight float64) {
-	p.items = append(p.items, &leastLoadedNode{item: item, weight: weight})
+func (p *leastLoaded[T]) Add(item T, weight float64) {
+	p.items = append(p.items, &leastLoadedNode[T]{item: item, id: p.id(item), inflight: new(int64), weight: weight})
+}
+
+// Inherit the inflight of the items still present in the replaced least loaded picker,
+// the requests picked by it are accounted by both until done
+func (p *leastLoaded[T]) Inherit(old loadbalance.PickerOf[T]) {
+	o, ok := old.(*leastLoaded[T])
+	if !ok {
+		return
+	}
+
+	inflight := make(map[interface{}]*int64, len(o.items))
+	for _, n := range o.items {
+		inflight[n.id] = n.inflight
+	}
+	for _, n := range p.items {
+		if i, ok := inflight[n.id]; ok {
+			n.inflight = i
+		}
+	}
+}
+
+// Remove the item, the inflight of the other items is kept
+func (p *leastLoaded[T]) Remove(item T) bool {
+	id := p.id(item)
+	items := make([]*leastLoadedNode[T], 0, len(p.items))
+	for _, n := range p.items {
+		if n.id != id {
+			items = append(items, n)
+		}
+	}
+
+	removed := len(items) < len(p.items)
+	p.items = items
+
+	return removed
 }
 
-func (p *leastLoaded) Reset() {
+// UpdateWeight of the item, the inflight of the item is kept
+func (p *leastLoaded[T]) UpdateWeight(item T, weight float64) bool {
+	id := p.id(item)
+	updated := false
+	for _, n := range p.items {
+		if n.id == id {
+			n.weight = weight
+			updated = true
+		}
+	}
+
+	return updated
+}
+
+// Track the item picked elsewhere, its inflight is increased until done
+func (p *leastLoaded[T]) Track(item T) (func(balancer.DoneInfo), bool) {
+	id := p.id(item)
+	for _, n := range p.items {
+		if n.id == id {
+			return n.pick(), true
+		}
+	}
+
+	return internal.EmptyDoneFunc, false
+}
+
+// Stats returns the weight and inflight of every item
+func (p *leastLoaded[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	stats := make([]loadbalance.NodeStatsOf[T], 0, len(p.items))
+	for _, n := range p.items {
+		stats = append(stats, n.stats())
+	}
+
+	return stats
+}
+
+func (p *leastLoaded[T]) Reset() {
 	p.items = p.items[:0]
 }
 
-func (p *leastLoaded) Next() (interface{}, func(balancer.DoneInfo)) {
-	var sc, backsc *leastLoadedNode
+func (p *leastLoaded[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := p.NextErr()
+	return item, done
+}
 
+func (p *leastLoaded[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	sc, _ := p.choose()
+	if sc == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
+	}
+
+	return sc.item, sc.pick(), nil
+}
+
+// NextCandidates returns the next selected item and the two candidates compared by inflight and weight
+func (p *leastLoaded[T]) NextCandidates() (T, func(balancer.DoneInfo), []loadbalance.NodeStatsOf[T], error) {
+	sc, backsc := p.choose()
+	if sc == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc, nil, loadbalance.ErrNoAvailableItem
+	}
+
+	candidates := []loadbalance.NodeStatsOf[T]{sc.stats()}
+	if backsc != nil {
+		candidates = append(candidates, backsc.stats())
+	}
+
+	return sc.item, sc.pick(), candidates, nil
+}
+
+// choose returns the selected node and the other candidate, nil if none
+func (p *leastLoaded[T]) choose() (sc, backsc *leastLoadedNode[T]) {
 	switch len(p.items) {
 	case 0:
-		return nil, internal.EmptyDoneFunc
+		return nil, nil
 	case 1:
 		sc = p.items[0]
 	default:
@@ -60,17 +194,13 @@ func (p *leastLoaded) Next() (interface{}, func(balancer.DoneInfo)) {
 		sc, backsc = p.items[a], p.items[b]
 
 		// choose the least loaded item based on inflight and weight
-		scInflight := atomic.LoadInt64(&sc.inflight)
-		backscInflight := atomic.LoadInt64(&backsc.inflight)
+		scInflight := atomic.LoadInt64(sc.inflight)
+		backscInflight := atomic.LoadInt64(backsc.inflight)
 
 		if float64(scInflight)*backsc.weight > float64(backscInflight)*sc.weight {
 			sc, backsc = backsc, sc
 		}
 	}
 
-	atomic.AddInt64(&sc.inflight, 1)
-
-	return sc.item, func(balancer.DoneInfo) {
-		atomic.AddInt64(&sc.inflight, -1)
-	}
+	return sc, backsc
 }
@@ -2,7 +2,9 @@ package p2c_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/hnlq715/go-loadbalance"
 	"github.com/hnlq715/go-loadbalance/p2c"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/balancer"
@@ -68,3 +70,181 @@ func TestLeastLoadedAbnormal(t *testing.T) {
 		}
 	})
 }
+
+func TestLeastLoadedOf(t *testing.T) {
+	type addr struct {
+		host string
+		port int
+	}
+
+	ll := p2c.NewLeastLoadedOf[addr]()
+	item, _ := ll.Next()
+	assert.Equal(t, addr{}, item)
+
+	ll.Add(addr{host: "10.0.0.1", port: 80}, 1)
+	ll.Add(addr{host: "10.0.0.2", port: 80}, 1)
+
+	item, _ = ll.Next()
+	next, _ := ll.Next()
+	assert.NotEqual(t, item, next)
+	assert.Equal(t, 80, next.port)
+}
+
+func TestLeastLoadedUpdate(t *testing.T) {
+	ll := p2c.NewLeastLoaded()
+	ll.Add(1, 1)
+	ll.Add(2, 1)
+	ll.Add(3, 1)
+
+	updater := ll.(loadbalance.Updater)
+	assert.True(t, updater.Remove(3))
+	assert.False(t, updater.Remove(3))
+
+	// the inflight of the picked item is kept after the other one is reweighted
+	item, _ := ll.Next()
+	other := 3 - item.(int)
+	assert.True(t, updater.UpdateWeight(other, 2))
+	assert.False(t, updater.UpdateWeight(3, 2))
+
+	for i := 0; i < 100; i++ {
+		next, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, other, next)
+	}
+
+	t.Run("key", func(t *testing.T) {
+		type addr struct {
+			host string
+			zone string
+		}
+
+		ll := p2c.NewLeastLoadedWithKey(func(a addr) string { return a.host })
+		ll.Add(addr{host: "10.0.0.1", zone: "bj-a"}, 1)
+		ll.Add(addr{host: "10.0.0.2", zone: "bj-b"}, 1)
+
+		// zone is not a part of the identity
+		assert.True(t, ll.(loadbalance.UpdaterOf[addr]).Remove(addr{host: "10.0.0.1"}))
+		item, _ := ll.Next()
+		assert.Equal(t, "10.0.0.2", item.host)
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		type conn struct {
+			addr  string
+			state int
+		}
+
+		// pointers are identified by themselves, not by their contents
+		a, b := &conn{addr: "10.0.0.1"}, &conn{addr: "10.0.0.1"}
+		ll := p2c.NewLeastLoadedOf[*conn]()
+		ll.Add(a, 1)
+		ll.Add(b, 1)
+
+		a.state = 1
+		updater := ll.(loadbalance.UpdaterOf[*conn])
+		assert.False(t, updater.Remove(&conn{addr: "10.0.0.1"}))
+		assert.True(t, updater.Remove(a))
+
+		for i := 0; i < 10; i++ {
+			item, done := ll.Next()
+			done(balancer.DoneInfo{})
+			assert.Same(t, b, item)
+		}
+	})
+
+	t.Run("not comparable", func(t *testing.T) {
+		ll := p2c.NewLeastLoadedOf[[]string]()
+		ll.Add([]string{"10.0.0.1"}, 1)
+		ll.Add([]string{"10.0.0.2"}, 1)
+
+		assert.True(t, ll.(loadbalance.UpdaterOf[[]string]).Remove([]string{"10.0.0.1"}))
+		item, _ := ll.Next()
+		assert.Equal(t, []string{"10.0.0.2"}, item)
+	})
+}
+
+func TestLeastLoadedTrack(t *testing.T) {
+	ll := p2c.NewLeastLoaded()
+	ll.Add(1, 1)
+	ll.Add(2, 1)
+
+	tracker := ll.(loadbalance.Tracker)
+	_, ok := tracker.Track(3)
+	assert.False(t, ok)
+
+	// the tracked item is loaded, so the other one is picked
+	done, ok := tracker.Track(1)
+	assert.True(t, ok)
+	for i := 0; i < 10; i++ {
+		item, done := ll.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, 2, item)
+	}
+
+	done(balancer.DoneInfo{})
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: 1, Weight: 1},
+		{Item: 2, Weight: 1},
+	}, ll.(loadbalance.Statser).Stats())
+}
+
+func TestLeastLoadedStats(t *testing.T) {
+	ll := p2c.NewLeastLoaded()
+	ll.Add(1, 1)
+
+	_, done := ll.Next()
+	ll.Next()
+	done(balancer.DoneInfo{})
+
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: 1, Weight: 1, Inflight: 1},
+	}, ll.(loadbalance.Statser).Stats())
+}
+
+func TestLeastLoadedInherit(t *testing.T) {
+	old := p2c.NewLeastLoaded()
+	old.Add(1, 1)
+	old.Add(2, 1)
+	done, _ := old.(loadbalance.Tracker).Track(2)
+
+	// the inflight of 2 is carried over, 3 starts from scratch
+	ll := p2c.NewLeastLoaded()
+	ll.Add(2, 1)
+	ll.Add(3, 1)
+	loadbalance.Inherit(ll, old)
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: 2, Weight: 1, Inflight: 1},
+		{Item: 3, Weight: 1},
+	}, ll.(loadbalance.Statser).Stats())
+
+	done(balancer.DoneInfo{})
+	assert.Equal(t, int64(0), ll.(loadbalance.Statser).Stats()[0].Inflight)
+
+	// pickers of other types are not inherited
+	pewma := p2c.NewPeakEwma()
+	pewma.Add(2, 1)
+	loadbalance.Inherit(pewma, old)
+	assert.Equal(t, time.Duration(0), pewma.(loadbalance.Statser).Stats()[0].EWMA)
+}
+
+func TestLeastLoadedCandidates(t *testing.T) {
+	ll := p2c.NewLeastLoaded()
+	_, _, candidates, err := loadbalance.NextCandidates[interface{}](ll)
+	assert.Equal(t, loadbalance.ErrNoAvailableItem, err)
+	assert.Nil(t, candidates)
+
+	ll.Add(1, 1)
+	_, done, candidates, _ := loadbalance.NextCandidates[interface{}](ll)
+	assert.Equal(t, []loadbalance.NodeStats{{Item: 1, Weight: 1}}, candidates)
+
+	// the loaded one loses
+	ll.Add(2, 1)
+	item, _, candidates, err := loadbalance.NextCandidates[interface{}](ll)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, item)
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: 2, Weight: 1},
+		{Item: 1, Weight: 1, Inflight: 1},
+	}, candidates)
+	done(balancer.DoneInfo{})
+}
@@ -53,43 +53,190 @@ func (p *peakEwma) Value() int64 {
 	return atomic.LoadInt64(&p.value)
 }
 
-type peakEwmaNode struct {
-	item    interface{}
+// Stamp returns the time of the last observed rtt, or nil if none observed
+func (p *peakEwma) Stamp() *time.Time {
+	stamp := atomic.LoadInt64(&p.stamp)
+	if stamp == 0 {
+		return nil
+	}
+
+	t := time.Unix(0, stamp)
+	return &t
+}
+
+type peakEwmaNode[T any] struct {
+	item    T
+	id      interface{}
 	latency *peakEwma
 	weight  float64
 }
 
-type pewma struct {
-	items []*peakEwmaNode
+// stats returns the weight and latency of the node
+func (n *peakEwmaNode[T]) stats() loadbalance.NodeStatsOf[T] {
+	return loadbalance.NodeStatsOf[T]{
+		Item:       n.item,
+		Weight:     n.weight,
+		EWMA:       time.Duration(n.latency.Value()),
+		LastSample: n.latency.Stamp(),
+	}
+}
+
+// pick observes the latency of the node when done
+func (n *peakEwmaNode[T]) pick() func(balancer.DoneInfo) {
+	begin := time.Now().UnixNano()
+
+	return func(balancer.DoneInfo) {
+		end := time.Now().UnixNano()
+		n.latency.Observe(end - begin)
+	}
+}
+
+type pewma[T any] struct {
+	items []*peakEwmaNode[T]
+	id    func(T) interface{}
 	mu    sync.Mutex
 	rand  *rand.Rand
 }
 
 func NewPeakEwma() loadbalance.Picker {
-	return &pewma{
-		items: make([]*peakEwmaNode, 0),
+	return NewPeakEwmaOf[interface{}]()
+}
+
+// NewPeakEwmaOf returns a peak EWMA picker of items of type T
+func NewPeakEwmaOf[T any]() loadbalance.PickerOf[T] {
+	return NewPeakEwmaWithKey[T](nil)
+}
+
+// NewPeakEwmaWithKey returns a peak EWMA picker of items of type T,
+// which are identified by key in Remove, UpdateWeight and Track,
+// or by the item itself if nil
+func NewPeakEwmaWithKey[T any](key loadbalance.KeyFunc[T]) loadbalance.PickerOf[T] {
+	return &pewma[T]{
+		items: make([]*peakEwmaNode[T], 0),
+		id:    internal.IdentityOf(key),
 		rand:  rand.New(rand.NewSource(time.Now().Unix())),
 	}
 }
 
-func (p *pewma) Add(item interface{}, weight float64) {
-	p.items = append(p.items, &peakEwmaNode{item: item, latency: newPEWMA(), weight: weight})
+func (p *pewma[T]) Add(item T, weight float64) {
+	p.items = append(p.items, &peakEwmaNode[T]{item: item, id: p.id(item), latency: newPEWMA(), weight: weight})
+}
+
+// Inherit the latency of the items still present in the replaced peak EWMA picker,
+// the requests picked by it are observed by both when done
+func (p *pewma[T]) Inherit(old loadbalance.PickerOf[T]) {
+	o, ok := old.(*pewma[T])
+	if !ok {
+		return
+	}
+
+	latency := make(map[interface{}]*peakEwma, len(o.items))
+	for _, n := range o.items {
+		latency[n.id] = n.latency
+	}
+	for _, n := range p.items {
+		if l, ok := latency[n.id]; ok {
+			n.latency = l
+		}
+	}
+}
+
+// Remove the item, the latency of the other items is kept
+func (p *pewma[T]) Remove(item T) bool {
+	id := p.id(item)
+	items := make([]*peakEwmaNode[T], 0, len(p.items))
+	for _, n := range p.items {
+		if n.id != id {
+			items = append(items, n)
+		}
+	}
+
+	removed := len(items) < len(p.items)
+	p.items = items
+
+	return removed
+}
+
+// UpdateWeight of the item, the latency of the item is kept
+func (p *pewma[T]) UpdateWeight(item T, weight float64) bool {
+	id := p.id(item)
+	updated := false
+	for _, n := range p.items {
+		if n.id == id {
+			n.weight = weight
+			updated = true
+		}
+	}
+
+	return updated
+}
+
+// Track the item picked elsewhere, its latency is observed when done
+func (p *pewma[T]) Track(item T) (func(balancer.DoneInfo), bool) {
+	id := p.id(item)
+	for _, n := range p.items {
+		if n.id == id {
+			return n.pick(), true
+		}
+	}
+
+	return internal.EmptyDoneFunc, false
+}
+
+// Stats returns the weight and latency of every item
+func (p *pewma[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	stats := make([]loadbalance.NodeStatsOf[T], 0, len(p.items))
+	for _, n := range p.items {
+		stats = append(stats, n.stats())
+	}
+
+	return stats
 }
 
-func (p *pewma) Reset() {
-	*p = pewma{
-		items: make([]*peakEwmaNode, 0),
+func (p *pewma[T]) Reset() {
+	*p = pewma[T]{
+		items: make([]*peakEwmaNode[T], 0),
+		id:    p.id,
 		rand:  rand.New(rand.NewSource(time.Now().Unix())),
 	}
 }
 
-func (p *pewma) Next() (interface{}, func(balancer.DoneInfo)) {
-	var sc, backsc *peakEwmaNode
-	begin := time.Now().UnixNano()
+func (p *pewma[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := p.NextErr()
+	return item, done
+}
+
+func (p *pewma[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	sc, _ := p.choose()
+	if sc == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
+	}
+
+	return sc.item, sc.pick(), nil
+}
+
+// NextCandidates returns the next selected item and the two candidates compared by latency and weight
+func (p *pewma[T]) NextCandidates() (T, func(balancer.DoneInfo), []loadbalance.NodeStatsOf[T], error) {
+	sc, backsc := p.choose()
+	if sc == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc, nil, loadbalance.ErrNoAvailableItem
+	}
+
+	candidates := []loadbalance.NodeStatsOf[T]{sc.stats()}
+	if backsc != nil {
+		candidates = append(candidates, backsc.stats())
+	}
 
+	return sc.item, sc.pick(), candidates, nil
+}
+
+// choose returns the selected node and the other candidate, nil if none
+func (p *pewma[T]) choose() (sc, backsc *peakEwmaNode[T]) {
 	switch len(p.items) {
 	case 0:
-		return nil, internal.EmptyDoneFunc
+		return nil, nil
 	case 1:
 		sc = p.items[0]
 	default:
@@ -111,8 +258,5 @@ func (p *pewma) Next() (interface{}, func(balancer.DoneInfo)) {
 		}
 	}
 
-	return sc.item, func(balancer.DoneInfo) {
-		end := time.Now().UnixNano()
-		sc.latency.Observe(end - begin)
-	}
+	return sc, backsc
 }
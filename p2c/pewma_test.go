@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hnlq715/go-loadbalance"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/balancer"
 )
@@ -83,3 +84,74 @@ func TestPeakEwma(t *testing.T) {
 		assert.Equal(t, totalCount, total)
 	})
 }
+
+func TestPeakEwmaUpdate(t *testing.T) {
+	p := NewPeakEwma()
+	p.Add(1, 1)
+	p.Add(2, 1)
+	p.Add(3, 1)
+
+	updater := p.(loadbalance.Updater)
+	assert.True(t, updater.Remove(3))
+	assert.False(t, updater.Remove(3))
+	assert.True(t, updater.UpdateWeight(2, 2))
+	assert.False(t, updater.UpdateWeight(3, 2))
+
+	// the latency of 1 is kept after 2 is reweighted
+	done, ok := p.(loadbalance.Tracker).Track(1)
+	assert.True(t, ok)
+	time.Sleep(time.Millisecond)
+	done(balancer.DoneInfo{})
+	assert.True(t, updater.UpdateWeight(2, 1))
+
+	for i := 0; i < 10; i++ {
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, 2, item)
+	}
+
+	stats := p.(loadbalance.Statser).Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, 1, stats[0].Item)
+	assert.GreaterOrEqual(t, stats[0].EWMA, time.Millisecond)
+}
+
+func TestPeakEwmaStats(t *testing.T) {
+	p := NewPeakEwma()
+	p.Add(1, 2)
+
+	stats := p.(loadbalance.Statser).Stats()
+	assert.Equal(t, []loadbalance.NodeStats{{Item: 1, Weight: 2}}, stats)
+
+	begin := time.Now()
+	_, done := p.Next()
+	time.Sleep(time.Millisecond)
+	done(balancer.DoneInfo{})
+
+	stats = p.(loadbalance.Statser).Stats()
+	assert.Len(t, stats, 1)
+	assert.GreaterOrEqual(t, stats[0].EWMA, time.Millisecond)
+	if assert.NotNil(t, stats[0].LastSample) {
+		assert.False(t, stats[0].LastSample.Before(begin))
+	}
+}
+
+func TestPeakEwmaInherit(t *testing.T) {
+	old := NewPeakEwma()
+	old.Add(1, 1)
+	old.Add(2, 1)
+	done, _ := old.(loadbalance.Tracker).Track(2)
+	time.Sleep(time.Millisecond)
+	done(balancer.DoneInfo{})
+
+	// the latency of 2 is carried over, 3 starts from scratch
+	p := NewPeakEwma()
+	p.Add(2, 1)
+	p.Add(3, 1)
+	loadbalance.Inherit(p, old)
+
+	stats := p.(loadbalance.Statser).Stats()
+	assert.GreaterOrEqual(t, stats[0].EWMA, time.Millisecond)
+	assert.NotNil(t, stats[0].LastSample)
+	assert.Equal(t, loadbalance.NodeStats{Item: 3, Weight: 1}, stats[1])
+}
@@ -0,0 +1,6 @@
+package priority
+
+// Loads returns the percentage of traffic for each priority level
+func (p *PriorityOf[T]) Loads() []float64 {
+	return p.loads[:]
+}
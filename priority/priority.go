@@ -0,0 +1,275 @@
+package priority
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"google.golang.org/grpc/balancer"
+)
+
+// priority levels grouped by locality, the lower the more preferred
+const (
+	// levelUnit contains items in the same region and unit
+	levelUnit int = iota
+	// levelRegion contains items in the same region but another unit
+	levelRegion
+	// levelAny contains items in any other region
+	levelAny
+
+	levelCount
+)
+
+const (
+	// defaultOverprovisioningFactor is the same as envoy's default,
+	// a level keeps all traffic until less than 1/1.4 (~71%) items are healthy
+	defaultOverprovisioningFactor float64 = 1.4
+
+	percent float64 = 100
+)
+
+type node[T comparable] struct {
+	item    T
+	weight  float64
+	info    loadbalance.SetInfo
+	level   int
+	healthy bool
+}
+
+// PriorityOf groups items by locality into priority levels,
+// and sends traffic to the most preferred healthy level,
+// spilling over to the less preferred levels proportionally
+// when the healthy fraction of a level drops.
+// It's the same as envoy's priority levels.
+type PriorityOf[T comparable] struct {
+	info                   loadbalance.SetInfo
+	overprovisioningFactor float64
+
+	nodes  []*node[T]
+	levels [levelCount]loadbalance.PickerOf[T]
+	loads  [levelCount]float64
+
+	observer loadbalance.ObserverOf[T]
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Priority is a PriorityOf items of any type
+type Priority = PriorityOf[interface{}]
+
+// New returns a Priority picker for the local set info
+func New(info loadbalance.SetInfo) *Priority {
+	return NewOf[interface{}](info)
+}
+
+// NewOf returns a PriorityOf picker for the local set info
+func NewOf[T comparable](info loadbalance.SetInfo) *PriorityOf[T] {
+	p := &PriorityOf[T]{
+		info:                   info,
+		overprovisioningFactor: defaultOverprovisioningFactor,
+		nodes:                  make([]*node[T], 0),
+		rand:                   rand.New(rand.NewSource(time.Now().Unix())),
+	}
+
+	for level := range p.levels {
+		p.levels[level] = roundrobin.NewSmoothRoundrobinOf[T]()
+	}
+
+	return p
+}
+
+var _ loadbalance.Set = (*Priority)(nil)
+
+// SetOverprovisioningFactor sets the overprovisioning factor
+func (p *PriorityOf[T]) SetOverprovisioningFactor(factor float64) {
+	if factor > 0 {
+		p.overprovisioningFactor = factor
+		p.rebuildLoads()
+	}
+}
+
+// Info returns the local set info
+func (p *PriorityOf[T]) Info() loadbalance.SetInfo {
+	return p.info
+}
+
+// SetObserver sets the observer notified of unhealthy items as ejected
+func (p *PriorityOf[T]) SetObserver(observer loadbalance.ObserverOf[T]) {
+	p.observer = observer
+}
+
+// Add a weighted item with set info, items belong to other sets are dropped
+func (p *PriorityOf[T]) Add(item T, weight float64, info loadbalance.SetInfo) {
+	if info.Name != p.info.Name {
+		return
+	}
+
+	n := &node[T]{item: item, weight: weight, info: info, level: p.level(info), healthy: true}
+	p.nodes = append(p.nodes, n)
+	p.levels[n.level].Add(item, weight)
+
+	p.rebuildLoads()
+}
+
+// SetHealthy marks the item as healthy or not,
+// unhealthy items never get picked
+func (p *PriorityOf[T]) SetHealthy(item T, healthy bool) {
+	changed := [levelCount]bool{}
+	for _, n := range p.nodes {
+		if n.item == item && n.healthy != healthy {
+			n.healthy = healthy
+			changed[n.level] = true
+
+			if p.observer != nil {
+				p.observer.OnEject(item, !healthy)
+			}
+		}
+	}
+
+	for level := range changed {
+		if changed[level] {
+			p.rebuildLevel(level)
+		}
+	}
+
+	p.rebuildLoads()
+}
+
+// Stats returns the state and set info of every item, unhealthy items are ejected
+func (p *PriorityOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	picked := make(map[T]loadbalance.NodeStatsOf[T], len(p.nodes))
+	for _, level := range p.levels {
+		for _, s := range loadbalance.Stats[T](level) {
+			picked[s.Item] = s
+		}
+	}
+
+	stats := make([]loadbalance.NodeStatsOf[T], 0, len(p.nodes))
+	for _, n := range p.nodes {
+		s, ok := picked[n.item]
+		if !ok {
+			s = loadbalance.NodeStatsOf[T]{Item: n.item, Weight: n.weight}
+		}
+		s.Ejected = !n.healthy
+		info := n.info
+		s.Set = &info
+		stats = append(stats, s)
+	}
+
+	return stats
+}
+
+// Reset this picker
+func (p *PriorityOf[T]) Reset() {
+	p.nodes = p.nodes[:0]
+	for level := range p.levels {
+		p.levels[level].Reset()
+	}
+
+	p.rebuildLoads()
+}
+
+// Next returns the next selected item
+func (p *PriorityOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := p.NextErr()
+	return item, done
+}
+
+// NextErr returns the next selected item,
+// or ErrAllEjected if there are items but none of them is healthy
+func (p *PriorityOf[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	// rand needs lock
+	p.mu.Lock()
+	r := p.rand.Float64() * percent
+	p.mu.Unlock()
+
+	for level, load := range p.loads {
+		if load <= 0 {
+			continue
+		}
+
+		if r < load {
+			return loadbalance.NextErr[T](p.levels[level])
+		}
+		r -= load
+	}
+
+	// float rounding may leave a tiny tail, goes to the last loaded level
+	for level := levelCount - 1; level >= 0; level-- {
+		if p.loads[level] > 0 {
+			return loadbalance.NextErr[T](p.levels[level])
+		}
+	}
+
+	var zero T
+	if len(p.nodes) > 0 {
+		return zero, internal.EmptyDoneFunc, loadbalance.ErrAllEjected
+	}
+
+	return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
+}
+
+// level returns the priority level of the set info by locality
+func (p *PriorityOf[T]) level(info loadbalance.SetInfo) int {
+	if info.Region != p.info.Region {
+		return levelAny
+	}
+
+	if p.info.UnitName == "*" || info.UnitName == p.info.UnitName {
+		return levelUnit
+	}
+
+	return levelRegion
+}
+
+// rebuildLevel rebuilds the picker of the level with healthy items only
+func (p *PriorityOf[T]) rebuildLevel(level int) {
+	p.levels[level].Reset()
+	for _, n := range p.nodes {
+		if n.level == level && n.healthy {
+			p.levels[level].Add(n.item, n.weight)
+		}
+	}
+}
+
+// rebuildLoads calculates the traffic percentage of each level
+// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/upstream/load_balancing/priority
+func (p *PriorityOf[T]) rebuildLoads() {
+	var total, healthy [levelCount]int
+	for _, n := range p.nodes {
+		total[n.level]++
+		if n.healthy {
+			healthy[n.level]++
+		}
+	}
+
+	var health [levelCount]float64
+	totalHealth := float64(0)
+	for level := range health {
+		if total[level] == 0 {
+			continue
+		}
+
+		ratio := float64(healthy[level]) / float64(total[level])
+		health[level] = math.Min(percent, p.overprovisioningFactor*ratio*percent)
+		totalHealth += health[level]
+	}
+
+	p.loads = [levelCount]float64{}
+	if totalHealth == 0 {
+		return
+	}
+
+	// normalize the loads when the total health is less than 100%
+	totalHealth = math.Min(percent, totalHealth)
+	remaining := percent
+	for level := range health {
+		p.loads[level] = math.Min(remaining, health[level]*percent/totalHealth)
+		remaining -= p.loads[level]
+	}
+}
@@ -0,0 +1,155 @@
+package priority_test
+
+import (
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/priority"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+var (
+	local   = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+	unit    = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+	region  = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "02"}
+	another = loadbalance.SetInfo{Name: "app", Region: "sh", UnitName: "01"}
+)
+
+func count(p *priority.Priority, totalCount int) map[interface{}]int {
+	countMap := make(map[interface{}]int)
+	for i := 0; i < totalCount; i++ {
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		countMap[item]++
+	}
+
+	return countMap
+}
+
+func TestPriority(t *testing.T) {
+	t.Run("0 item", func(t *testing.T) {
+		p := priority.New(local)
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+	})
+
+	t.Run("different name", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, loadbalance.SetInfo{Name: "other", Region: "bj", UnitName: "01"})
+
+		item, _ := p.Next()
+		assert.Nil(t, item)
+	})
+
+	t.Run("all healthy", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Add(2, 1, unit)
+		p.Add(3, 1, region)
+		p.Add(4, 1, another)
+
+		assert.Equal(t, []float64{100, 0, 0}, p.Loads())
+
+		countMap := count(p, 1000)
+		assert.Equal(t, 500, countMap[1])
+		assert.Equal(t, 500, countMap[2])
+	})
+
+	t.Run("partial healthy", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Add(2, 1, unit)
+		p.Add(3, 1, region)
+		p.Add(4, 1, another)
+
+		p.SetHealthy(2, false)
+		// 1.4 * 50% = 70%
+		assert.InDeltaSlice(t, []float64{70, 30, 0}, p.Loads(), 0.001)
+
+		totalCount := 10000
+		countMap := count(p, totalCount)
+		assert.Zero(t, countMap[2])
+		assert.Zero(t, countMap[4])
+		assert.InDelta(t, totalCount*7/10, countMap[1], 300)
+		assert.InDelta(t, totalCount*3/10, countMap[3], 300)
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Add(3, 1, region)
+		p.Add(4, 1, another)
+
+		p.SetHealthy(1, false)
+		assert.Equal(t, []float64{0, 100, 0}, p.Loads())
+
+		item, _ := p.Next()
+		assert.Equal(t, 3, item)
+
+		p.SetHealthy(3, false)
+		assert.Equal(t, []float64{0, 0, 100}, p.Loads())
+
+		item, _ = p.Next()
+		assert.Equal(t, 4, item)
+
+		p.SetHealthy(4, false)
+		item, _ = p.Next()
+		assert.Nil(t, item)
+
+		p.SetHealthy(1, true)
+		item, _ = p.Next()
+		assert.Equal(t, 1, item)
+	})
+
+	t.Run("normalized", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Add(2, 1, unit)
+		p.Add(3, 1, unit)
+		p.Add(4, 1, region)
+		p.Add(5, 1, region)
+
+		p.SetHealthy(1, false)
+		p.SetHealthy(2, false)
+		p.SetHealthy(4, false)
+		p.SetHealthy(5, false)
+
+		// only 1.4 * 33% = 46.7% healthy, scales up to 100%
+		assert.InDeltaSlice(t, []float64{100, 0, 0}, p.Loads(), 0.001)
+	})
+
+	t.Run("overprovisioning factor", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Add(2, 1, unit)
+		p.Add(3, 1, region)
+
+		p.SetOverprovisioningFactor(1)
+		p.SetHealthy(2, false)
+		assert.InDeltaSlice(t, []float64{50, 50, 0}, p.Loads(), 0.001)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		p := priority.New(local)
+		p.Add(1, 1, unit)
+		p.Reset()
+
+		assert.Equal(t, []float64{0, 0, 0}, p.Loads())
+		item, _ := p.Next()
+		assert.Nil(t, item)
+	})
+}
+
+func TestPriorityStats(t *testing.T) {
+	p := priority.New(local)
+	p.Add(1, 1, unit)
+	p.Add(2, 2, region)
+	p.SetHealthy(2, false)
+
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: 1, Weight: 1, EffectiveWeight: 1, Set: &unit},
+		{Item: 2, Weight: 2, Ejected: true, Set: &region},
+	}, p.Stats())
+}
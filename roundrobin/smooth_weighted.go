@@ -9,16 +9,18 @@ import (
 )
 
 // smoothRoundrobinNode is a wrapped weighted item.
-type smoothRoundrobinNode struct {
-	Item            interface{}
+type smoothRoundrobinNode[T any] struct {
+	Item            T
+	ID              interface{}
 	Weight          int64
 	CurrentWeight   int64
 	EffectiveWeight int64
 }
 
-type smoothRoundrobin struct {
-	items []*smoothRoundrobinNode
+type smoothRoundrobin[T any] struct {
+	items []*smoothRoundrobinNode[T]
 	n     int64
+	id    func(T) interface{}
 }
 
 // NewSmoothRoundrobin (Smooth Weighted) contains weighted items and provides methods to select a weighted item.
@@ -33,37 +35,116 @@ type smoothRoundrobin struct {
 // In case of { 5, 1, 1 } weights this gives the following sequence of
 // current_weight's: (a, a, b, a, c, a, a)
 func NewSmoothRoundrobin() loadbalance.Picker {
-	return &smoothRoundrobin{}
+	return NewSmoothRoundrobinOf[interface{}]()
+}
+
+// NewSmoothRoundrobinOf returns a smooth weighted round-robin picker of items of type T
+func NewSmoothRoundrobinOf[T any]() loadbalance.PickerOf[T] {
+	return NewSmoothRoundrobinWithKey[T](nil)
+}
+
+// NewSmoothRoundrobinWithKey returns a smooth weighted round-robin picker of items of type T,
+// which are identified by key in Remove, UpdateWeight and Track,
+// or by the item itself if nil
+func NewSmoothRoundrobinWithKey[T any](key loadbalance.KeyFunc[T]) loadbalance.PickerOf[T] {
+	return &smoothRoundrobin[T]{id: internal.IdentityOf(key)}
 }
 
 // Add a weighted server.
-func (w *smoothRoundrobin) Add(item interface{}, weight float64) {
+func (w *smoothRoundrobin[T]) Add(item T, weight float64) {
 	wt := int64(math.Floor(weight))
-	weighted := &smoothRoundrobinNode{Item: item, Weight: wt, EffectiveWeight: wt}
+	weighted := &smoothRoundrobinNode[T]{Item: item, ID: w.id(item), Weight: wt, EffectiveWeight: wt}
 	w.items = append(w.items, weighted)
 	w.n++
 }
 
-func (w *smoothRoundrobin) Reset() {
+// Remove a server, the current weights of the other servers are kept.
+func (w *smoothRoundrobin[T]) Remove(item T) bool {
+	id := w.id(item)
+	items := make([]*smoothRoundrobinNode[T], 0, len(w.items))
+	for _, weighted := range w.items {
+		if weighted.ID != id {
+			items = append(items, weighted)
+		}
+	}
+
+	removed := len(items) < len(w.items)
+	w.items = items
+	w.n = int64(len(items))
+
+	return removed
+}
+
+// UpdateWeight of a server, the current weight of the server is kept.
+func (w *smoothRoundrobin[T]) UpdateWeight(item T, weight float64) bool {
+	id := w.id(item)
+	wt := int64(math.Floor(weight))
+	updated := false
+	for _, weighted := range w.items {
+		if weighted.ID == id {
+			weighted.Weight = wt
+			weighted.EffectiveWeight = wt
+			updated = true
+		}
+	}
+
+	return updated
+}
+
+// Track a server picked elsewhere, nothing is accounted but its presence.
+func (w *smoothRoundrobin[T]) Track(item T) (func(balancer.DoneInfo), bool) {
+	id := w.id(item)
+	for _, weighted := range w.items {
+		if weighted.ID == id {
+			return internal.EmptyDoneFunc, true
+		}
+	}
+
+	return internal.EmptyDoneFunc, false
+}
+
+// Stats returns the weights of every server.
+func (w *smoothRoundrobin[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	stats := make([]loadbalance.NodeStatsOf[T], 0, len(w.items))
+	for _, weighted := range w.items {
+		stats = append(stats, loadbalance.NodeStatsOf[T]{
+			Item:            weighted.Item,
+			Weight:          float64(weighted.Weight),
+			EffectiveWeight: float64(weighted.EffectiveWeight),
+			CurrentWeight:   float64(weighted.CurrentWeight),
+		})
+	}
+
+	return stats
+}
+
+func (w *smoothRoundrobin[T]) Reset() {
 	w.items = w.items[:0]
 	w.n = 0
 }
 
 // Next returns next selected server.
-func (w *smoothRoundrobin) Next() (interface{}, func(balancer.DoneInfo)) {
+func (w *smoothRoundrobin[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := w.NextErr()
+	return item, done
+}
+
+// NextErr returns next selected server, or ErrNoAvailableItem if empty.
+func (w *smoothRoundrobin[T]) NextErr() (T, func(balancer.DoneInfo), error) {
 	if w.n == 0 {
-		return nil, internal.EmptyDoneFunc
+		var zero T
+		return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
 	}
 
 	if w.n == 1 {
-		return w.items[0].Item, internal.EmptyDoneFunc
+		return w.items[0].Item, internal.EmptyDoneFunc, nil
 	}
 
-	return nextSmoothWeighted(w.items).Item, internal.EmptyDoneFunc
+	return nextSmoothWeighted(w.items).Item, internal.EmptyDoneFunc, nil
 }
 
 // nextSmoothWeighted selects the best node through the smooth weighted roundrobin .
-func nextSmoothWeighted(items []*smoothRoundrobinNode) (best *smoothRoundrobinNode) {
+func nextSmoothWeighted[T any](items []*smoothRoundrobinNode[T]) (best *smoothRoundrobinNode[T]) {
 	total := int64(0)
 
 	for i := 0; i < len(items); i++ {
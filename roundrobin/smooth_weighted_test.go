@@ -3,6 +3,7 @@ package roundrobin
 import (
 	"testing"
 
+	"github.com/hnlq715/go-loadbalance"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/balancer"
 )
@@ -40,7 +41,68 @@ func TestSW_Next(t *testing.T) {
 		t.Error("the algorithm is wrong")
 	}
 
-	w.(*smoothRoundrobin).items[0].EffectiveWeight = w.(*smoothRoundrobin).items[0].CurrentWeight - 1
+	w.(*smoothRoundrobin[interface{}]).items[0].EffectiveWeight = w.(*smoothRoundrobin[interface{}]).items[0].CurrentWeight - 1
 	s, _ = w.Next()
 	assert.Equal(t, "server3", s.(string))
 }
+
+func TestSW_NextOf(t *testing.T) {
+	w := NewSmoothRoundrobinOf[string]()
+
+	s, _ := w.Next()
+	assert.Equal(t, "", s)
+
+	w.Add("server1", 2)
+	w.Add("server2", 1)
+
+	results := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		s, _ := w.Next()
+		results[s]++
+	}
+
+	assert.Equal(t, map[string]int{"server1": 200, "server2": 100}, results)
+}
+
+func TestSW_Update(t *testing.T) {
+	w := NewSmoothRoundrobin()
+	w.Add("server1", 1)
+	w.Add("server2", 1)
+	w.Add("server3", 1)
+
+	s, _ := w.Next()
+	assert.Equal(t, "server1", s)
+
+	updater := w.(loadbalance.Updater)
+	assert.True(t, updater.Remove("server1"))
+	assert.False(t, updater.Remove("server1"))
+
+	// the sequence goes on with the current weights
+	s, _ = w.Next()
+	assert.Equal(t, "server2", s)
+	s, _ = w.Next()
+	assert.Equal(t, "server3", s)
+
+	assert.True(t, updater.UpdateWeight("server2", 3))
+	assert.False(t, updater.UpdateWeight("server1", 3))
+
+	results := make(map[interface{}]int)
+	for i := 0; i < 400; i++ {
+		s, _ := w.Next()
+		results[s]++
+	}
+
+	assert.Equal(t, map[interface{}]int{"server2": 300, "server3": 100}, results)
+}
+
+func TestSW_Stats(t *testing.T) {
+	w := NewSmoothRoundrobin()
+	w.Add("server1", 2)
+	w.Add("server2", 1)
+	w.Next()
+
+	assert.Equal(t, []loadbalance.NodeStats{
+		{Item: "server1", Weight: 2, EffectiveWeight: 2, CurrentWeight: -1},
+		{Item: "server2", Weight: 1, EffectiveWeight: 1, CurrentWeight: 1},
+	}, w.(loadbalance.Statser).Stats())
+}
@@ -0,0 +1,202 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+// HeaderMatcher matches the request metadata by name,
+// exactly one of Exact, Prefix and Present must be set
+type HeaderMatcher struct {
+	// Name of the metadata, like `x-canary`
+	Name string `json:"name"`
+	// Exact matches if any value is equal to it
+	Exact string `json:"exact,omitempty"`
+	// Prefix matches if any value has the prefix
+	Prefix string `json:"prefix,omitempty"`
+	// Present matches if the metadata is present
+	Present bool `json:"present,omitempty"`
+	// Invert inverts the match result
+	Invert bool `json:"invert,omitempty"`
+}
+
+func (m HeaderMatcher) matches(md metadata.MD) bool {
+	values := md.Get(m.Name)
+
+	matched := false
+	switch {
+	case m.Exact != "":
+		for _, v := range values {
+			if v == m.Exact {
+				matched = true
+				break
+			}
+		}
+	case m.Prefix != "":
+		for _, v := range values {
+			if strings.HasPrefix(v, m.Prefix) {
+				matched = true
+				break
+			}
+		}
+	case m.Present:
+		matched = len(values) > 0
+	}
+
+	return matched != m.Invert
+}
+
+// validate checks the name is set and exactly one kind of match is set
+func (m HeaderMatcher) validate() error {
+	if m.Name == "" {
+		return errors.New("empty header name")
+	}
+
+	kinds := 0
+	for _, set := range []bool{m.Exact != "", m.Prefix != "", m.Present} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("header %q must set exactly one of exact, prefix and present", m.Name)
+	}
+
+	return nil
+}
+
+// Rule routes the request to the target when all headers match
+type Rule struct {
+	// Headers must all match, a rule without headers matches everything
+	Headers []HeaderMatcher `json:"headers"`
+	// Target is the name of the child picker
+	Target string `json:"target"`
+}
+
+func (r Rule) matches(md metadata.MD) bool {
+	for _, m := range r.Headers {
+		if !m.matches(md) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Config contains ordered rules and the default target, like
+//
+//	{
+//	  "rules": [
+//	    {"headers": [{"name": "x-canary", "exact": "true"}], "target": "canary"},
+//	    {"headers": [{"name": "x-tenant", "prefix": "vip-"}], "target": "vip"}
+//	  ],
+//	  "default": "stable"
+//	}
+type Config struct {
+	// Rules are matched in order, the first matched one wins
+	Rules []Rule `json:"rules"`
+	// Default target when no rule matches, no item is picked if empty
+	Default string `json:"default,omitempty"`
+}
+
+// ParseConfig parses the JSON config
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("route: invalid config: %w", err)
+	}
+
+	return config, nil
+}
+
+// RouterOf selects among child pickers by the request metadata
+type RouterOf[T any] struct {
+	rules         []Rule
+	targets       []loadbalance.NexterOf[T]
+	defaultTarget loadbalance.NexterOf[T]
+}
+
+// Router is a RouterOf items of any type
+type Router = RouterOf[interface{}]
+
+// New returns a Router, every target in config must be in targets
+func New(config Config, targets map[string]loadbalance.Nexter) (*Router, error) {
+	return NewOf(config, targets)
+}
+
+// NewOf returns a RouterOf, every target in config must be in targets
+func NewOf[T any](config Config, targets map[string]loadbalance.NexterOf[T]) (*RouterOf[T], error) {
+	r := &RouterOf[T]{
+		rules:   config.Rules,
+		targets: make([]loadbalance.NexterOf[T], 0, len(config.Rules)),
+	}
+
+	for _, rule := range config.Rules {
+		target, ok := targets[rule.Target]
+		if !ok {
+			return nil, fmt.Errorf("route: unknown target %q", rule.Target)
+		}
+
+		for _, m := range rule.Headers {
+			if err := m.validate(); err != nil {
+				return nil, fmt.Errorf("route: %s for target %q", err, rule.Target)
+			}
+		}
+
+		r.targets = append(r.targets, target)
+	}
+
+	if config.Default != "" {
+		target, ok := targets[config.Default]
+		if !ok {
+			return nil, fmt.Errorf("route: unknown default target %q", config.Default)
+		}
+
+		r.defaultTarget = target
+	}
+
+	return r, nil
+}
+
+// NewFromJSON returns a Router with the JSON config
+func NewFromJSON(data []byte, targets map[string]loadbalance.Nexter) (*Router, error) {
+	return NewFromJSONOf(data, targets)
+}
+
+// NewFromJSONOf returns a RouterOf with the JSON config
+func NewFromJSONOf[T any](data []byte, targets map[string]loadbalance.NexterOf[T]) (*RouterOf[T], error) {
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOf(config, targets)
+}
+
+// Pick returns the next selected item of the first matched target
+func (r *RouterOf[T]) Pick(info balancer.PickInfo) (T, func(balancer.DoneInfo)) {
+	var md metadata.MD
+	if info.Ctx != nil {
+		md, _ = metadata.FromOutgoingContext(info.Ctx)
+	}
+
+	for idx, rule := range r.rules {
+		if rule.matches(md) {
+			return r.targets[idx].Next()
+		}
+	}
+
+	if r.defaultTarget != nil {
+		return r.defaultTarget.Next()
+	}
+
+	var zero T
+	return zero, internal.EmptyDoneFunc
+}
@@ -0,0 +1,116 @@
+package route_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/route"
+	"github.com/hnlq715/go-loadbalance/set"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+const config = `{
+	"rules": [
+		{"headers": [{"name": "x-canary", "exact": "true"}], "target": "canary"},
+		{"headers": [{"name": "X-Tenant", "prefix": "vip-"}, {"name": "x-debug", "present": true, "invert": true}], "target": "vip"},
+		{"headers": [{"name": "x-set", "present": true}], "target": "set"}
+	],
+	"default": "stable"
+}`
+
+func newPicker(item interface{}) loadbalance.Picker {
+	p := roundrobin.NewSmoothRoundrobin()
+	p.Add(item, 1)
+	return p
+}
+
+func pickInfo(kv ...string) balancer.PickInfo {
+	return balancer.PickInfo{
+		FullMethodName: "/service/Method",
+		Ctx:            metadata.AppendToOutgoingContext(context.Background(), kv...),
+	}
+}
+
+func TestRouter(t *testing.T) {
+	s := set.New(loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"})
+	s.Add("set", 1, loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"})
+
+	r, err := route.NewFromJSON([]byte(config), map[string]loadbalance.Nexter{
+		"stable": newPicker("stable"),
+		"canary": newPicker("canary"),
+		"vip":    newPicker("vip"),
+		"set":    s,
+	})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name string
+		info balancer.PickInfo
+		item interface{}
+	}{
+		{"no metadata", balancer.PickInfo{}, "stable"},
+		{"empty metadata", pickInfo(), "stable"},
+		{"canary", pickInfo("x-canary", "true"), "canary"},
+		{"not canary", pickInfo("x-canary", "false"), "stable"},
+		{"canary first", pickInfo("x-canary", "true", "x-tenant", "vip-1"), "canary"},
+		{"vip", pickInfo("x-tenant", "vip-1"), "vip"},
+		{"vip debug", pickInfo("x-tenant", "vip-1", "x-debug", "1"), "stable"},
+		{"not vip", pickInfo("x-tenant", "1-vip"), "stable"},
+		{"set", pickInfo("x-set", "01"), "set"},
+	}
+
+	for _, c := range cases {
+		item, done := r.Pick(c.info)
+		done(balancer.DoneInfo{})
+		assert.Equal(t, c.item, item, c.name)
+	}
+}
+
+func TestRouterNoDefault(t *testing.T) {
+	r, err := route.New(route.Config{
+		Rules: []route.Rule{
+			{Headers: []route.HeaderMatcher{{Name: "x-canary", Exact: "true"}}, Target: "canary"},
+		},
+	}, map[string]loadbalance.Nexter{
+		"canary": newPicker("canary"),
+	})
+	assert.NoError(t, err)
+
+	item, done := r.Pick(pickInfo())
+	done(balancer.DoneInfo{})
+	assert.Nil(t, item)
+
+	item, _ = r.Pick(pickInfo("x-canary", "true"))
+	assert.Equal(t, "canary", item)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	targets := map[string]loadbalance.Nexter{
+		"stable": newPicker("stable"),
+	}
+
+	_, err := route.NewFromJSON([]byte(`{"rules": [`), targets)
+	assert.EqualError(t, err, "route: invalid config: unexpected end of JSON input")
+
+	_, err = route.NewFromJSON([]byte(`{"rules": [{"target": "canary"}]}`), targets)
+	assert.EqualError(t, err, `route: unknown target "canary"`)
+
+	_, err = route.NewFromJSON([]byte(`{"rules": [{"headers": [{"exact": "1"}], "target": "stable"}]}`), targets)
+	assert.EqualError(t, err, `route: empty header name for target "stable"`)
+
+	_, err = route.NewFromJSON([]byte(`{"rules": [{"headers": [{"name": "x-canary"}], "target": "stable"}]}`), targets)
+	assert.EqualError(t, err, `route: header "x-canary" must set exactly one of exact, prefix and present for target "stable"`)
+
+	_, err = route.NewFromJSON([]byte(`{"rules": [{"headers": [{"name": "x-canary", "present": false}], "target": "stable"}]}`), targets)
+	assert.EqualError(t, err, `route: header "x-canary" must set exactly one of exact, prefix and present for target "stable"`)
+
+	_, err = route.NewFromJSON([]byte(`{"rules": [{"headers": [{"name": "x-canary", "exact": "true", "prefix": "t"}], "target": "stable"}]}`), targets)
+	assert.EqualError(t, err, `route: header "x-canary" must set exactly one of exact, prefix and present for target "stable"`)
+
+	_, err = route.NewFromJSON([]byte(`{"default": "canary"}`), targets)
+	assert.EqualError(t, err, `route: unknown default target "canary"`)
+}
@@ -0,0 +1,65 @@
+package set
+
+import (
+	"path"
+	"strings"
+
+	"github.com/hnlq715/go-loadbalance"
+)
+
+const (
+	wildcard  = "*"
+	separator = "."
+)
+
+// Matcher reports whether the item with set info belongs to the target set
+type Matcher func(target, info loadbalance.SetInfo) bool
+
+// Match is the default Matcher, every field of target is a pattern:
+//   - glob patterns like `app-*` or `bj?` are supported on every field
+//   - unit names are hierarchical, `gz.01.*` matches `gz.01.a` and `gz.01.a.b`
+//   - an empty zone matches any zone
+func Match(target, info loadbalance.SetInfo) bool {
+	if !MatchPattern(target.Name, info.Name) {
+		return false
+	}
+
+	if !MatchPattern(target.Region, info.Region) {
+		return false
+	}
+
+	if target.Zone != "" && !MatchPattern(target.Zone, info.Zone) {
+		return false
+	}
+
+	return MatchPattern(target.UnitName, info.UnitName)
+}
+
+// MatchPattern matches the value against the hierarchical glob pattern,
+// which is separated into segments by `.`, a trailing `*` segment matches
+// all the remaining segments, and other segments are matched by path.Match
+func MatchPattern(pattern, value string) bool {
+	if pattern == value || pattern == wildcard {
+		return true
+	}
+
+	patterns := strings.Split(pattern, separator)
+	values := strings.Split(value, separator)
+
+	for i, p := range patterns {
+		if i == len(values) {
+			return false
+		}
+
+		if p == wildcard && i == len(patterns)-1 {
+			return true
+		}
+
+		// path.Match only fails on bad patterns, which never match
+		if ok, _ := path.Match(p, values[i]); !ok {
+			return false
+		}
+	}
+
+	return len(patterns) == len(values)
+}
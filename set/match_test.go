@@ -0,0 +1,92 @@
+package set_test
+
+import (
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/set"
+	"gotest.tools/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"app", "app", true},
+		{"app", "app2", false},
+		{"", "", true},
+		{"", "app", false},
+		{"*", "", true},
+		{"*", "gz.01.a", true},
+		{"app-*", "app-web", true},
+		{"app-*", "web-app", false},
+		{"bj?", "bj1", true},
+		{"bj?", "bj12", false},
+		{"[ab]j", "bj", true},
+		{"gz.01.*", "gz.01.a", true},
+		{"gz.01.*", "gz.01.a.b", true},
+		{"gz.01.*", "gz.01", false},
+		{"gz.01.*", "gz.02.a", false},
+		{"gz.*.a", "gz.01.a", true},
+		{"gz.*.a", "gz.01.b", false},
+		{"gz.*.a", "gz.01.a.b", false},
+		{"gz.0?", "gz.01", true},
+		{"gz.01", "gz.01.a", false},
+		{"[", "[", true},
+		{"[", "a", false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, set.MatchPattern(c.pattern, c.value), "%q %q", c.pattern, c.value)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	target := loadbalance.SetInfo{Name: "app-*", Region: "bj", UnitName: "gz.01.*"}
+
+	assert.Assert(t, set.Match(target, loadbalance.SetInfo{Name: "app-web", Region: "bj", Zone: "bj-a", UnitName: "gz.01.a"}))
+	assert.Assert(t, !set.Match(target, loadbalance.SetInfo{Name: "web", Region: "bj", UnitName: "gz.01.a"}))
+	assert.Assert(t, !set.Match(target, loadbalance.SetInfo{Name: "app-web", Region: "sh", UnitName: "gz.01.a"}))
+	assert.Assert(t, !set.Match(target, loadbalance.SetInfo{Name: "app-web", Region: "bj", UnitName: "gz.02.a"}))
+
+	target.Zone = "bj-?"
+	assert.Assert(t, set.Match(target, loadbalance.SetInfo{Name: "app-web", Region: "bj", Zone: "bj-a", UnitName: "gz.01.a"}))
+	assert.Assert(t, !set.Match(target, loadbalance.SetInfo{Name: "app-web", Region: "bj", Zone: "sh-a", UnitName: "gz.01.a"}))
+}
+
+func TestSelector(t *testing.T) {
+	selector, err := set.ParseSelector("env=prod, canary!=true,region, !debug,tier==web")
+	assert.NilError(t, err)
+
+	assert.Assert(t, selector.Matches(map[string]string{"env": "prod", "region": "bj", "tier": "web"}))
+	assert.Assert(t, selector.Matches(map[string]string{"env": "prod", "region": "bj", "tier": "web", "canary": "false"}))
+	assert.Assert(t, !selector.Matches(map[string]string{"env": "prod", "region": "bj", "tier": "web", "canary": "true"}))
+	assert.Assert(t, !selector.Matches(map[string]string{"env": "test", "region": "bj", "tier": "web"}))
+	assert.Assert(t, !selector.Matches(map[string]string{"env": "prod", "tier": "web"}))
+	assert.Assert(t, !selector.Matches(map[string]string{"env": "prod", "region": "bj", "tier": "web", "debug": ""}))
+	assert.Assert(t, !selector.Matches(nil))
+
+	empty, err := set.ParseSelector("")
+	assert.NilError(t, err)
+	assert.Assert(t, empty.Matches(nil))
+
+	for _, s := range []string{"=prod", "!", "env=prod=1", "a=!b"} {
+		_, err := set.ParseSelector(s)
+		assert.ErrorContains(t, err, "invalid selector", s)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	labels := loadbalance.NewLabels(map[string]string{"env": "prod", "canary": "true", "debug": ""})
+	assert.Equal(t, loadbalance.Labels("canary=true,debug=,env=prod"), labels)
+	assert.DeepEqual(t, map[string]string{"env": "prod", "canary": "true", "debug": ""}, labels.Map())
+	assert.DeepEqual(t, map[string]string{}, loadbalance.Labels("").Map())
+
+	// set info with labels is still comparable
+	a := loadbalance.SetInfo{Name: "app", Labels: loadbalance.NewLabels(map[string]string{"env": "prod", "canary": "true"})}
+	b := loadbalance.SetInfo{Name: "app", Labels: "canary=true,env=prod"}
+	assert.Assert(t, a == b)
+	assert.Equal(t, 1, len(map[loadbalance.SetInfo]bool{a: true, b: true}))
+}
@@ -0,0 +1,277 @@
+package set
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"google.golang.org/grpc/balancer"
+)
+
+type setInfoKey struct{}
+
+// NewContext returns a new context carrying the requested set info
+func NewContext(ctx context.Context, info loadbalance.SetInfo) context.Context {
+	return context.WithValue(ctx, setInfoKey{}, info)
+}
+
+// FromContext returns the requested set info in ctx if any
+func FromContext(ctx context.Context) (loadbalance.SetInfo, bool) {
+	info, ok := ctx.Value(setInfoKey{}).(loadbalance.SetInfo)
+	return info, ok
+}
+
+// routerKey identifies a Set in the Router, labels are not included
+type routerKey struct {
+	name     string
+	region   string
+	zone     string
+	unitName string
+}
+
+func newRouterKey(info loadbalance.SetInfo) routerKey {
+	return routerKey{
+		name:     info.Name,
+		region:   info.Region,
+		zone:     info.Zone,
+		unitName: info.UnitName,
+	}
+}
+
+// routerSet is a cached Set, Next of the same Set is serialized by mu
+type routerSet[T any] struct {
+	set loadbalance.SetOf[T]
+	mu  sync.Mutex
+}
+
+func (s *routerSet[T]) next() (T, func(balancer.DoneInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.set.Next()
+}
+
+type routerItem[T any] struct {
+	item   T
+	weight float64
+	info   loadbalance.SetInfo
+}
+
+// RouterOf ingests all items with their set info once,
+// and builds one Set for each requested set info on demand.
+// Sets are only built for set infos matching any ingested item,
+// and at most maxSets of them are cached.
+// The zero value of T means no item is picked.
+type RouterOf[T comparable] struct {
+	items     []routerItem[T]
+	sets      map[routerKey]*routerSet[T]
+	newPicker loadbalance.PickerFactoryOf[T]
+	// setOptions matches items the same way as the Sets built
+	setOptions options
+	routerOptions
+
+	// mu is read locked across Next, so Add and Reset never interleave with a pick,
+	// picks of different Sets only contend on the lock of their own Set
+	mu sync.RWMutex
+}
+
+// Router is a RouterOf items of any type
+type Router = RouterOf[interface{}]
+
+type routerOptions struct {
+	options     []Option
+	defaultInfo *loadbalance.SetInfo
+	maxSets     int
+}
+
+// defaultMaxSets is the max number of Sets cached by the Router
+const defaultMaxSets = 256
+
+// RouterOption configures the Router
+type RouterOption func(*routerOptions)
+
+// WithDefault falls back to the default set
+// when the requested set is empty or not provided
+func WithDefault(info loadbalance.SetInfo) RouterOption {
+	return func(o *routerOptions) {
+		o.defaultInfo = &info
+	}
+}
+
+// WithMaxSets sets the max number of Sets cached by the Router,
+// an arbitrary one is evicted when the cache is full
+func WithMaxSets(n int) RouterOption {
+	return func(o *routerOptions) {
+		if n > 0 {
+			o.maxSets = n
+		}
+	}
+}
+
+// WithSetOptions configures every Set built by the Router
+func WithSetOptions(opts ...Option) RouterOption {
+	return func(o *routerOptions) {
+		o.options = opts
+	}
+}
+
+// NewRouter returns a Router builds Sets with smooth roundrobin
+func NewRouter(opts ...RouterOption) *Router {
+	return NewRouterOf[interface{}](nil, opts...)
+}
+
+// NewRouterOf returns a RouterOf builds Sets with the inner Picker created by factory,
+// the smooth roundrobin Picker is used if nil
+func NewRouterOf[T comparable](factory loadbalance.PickerFactoryOf[T], opts ...RouterOption) *RouterOf[T] {
+	r := &RouterOf[T]{
+		items:         make([]routerItem[T], 0),
+		sets:          make(map[routerKey]*routerSet[T]),
+		newPicker:     factory,
+		setOptions:    options{matcher: Match},
+		routerOptions: routerOptions{maxSets: defaultMaxSets},
+	}
+
+	for _, opt := range opts {
+		opt(&r.routerOptions)
+	}
+	for _, opt := range r.options {
+		opt(&r.setOptions)
+	}
+
+	return r
+}
+
+// Add a weighted item with set info.
+func (r *RouterOf[T]) Add(item T, weight float64, info loadbalance.SetInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items = append(r.items, routerItem[T]{item: item, weight: weight, info: info})
+	for _, s := range r.sets {
+		s.set.Add(item, weight, info)
+	}
+}
+
+// Reset this router, the cached Sets are reset and dropped
+func (r *RouterOf[T]) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items = r.items[:0]
+	for _, s := range r.sets {
+		s.set.Reset()
+	}
+	r.sets = make(map[routerKey]*routerSet[T])
+}
+
+// Next returns the next selected item of the default set,
+// or the zero value if no default set
+func (r *RouterOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	return r.pickDefault()
+}
+
+// Stats returns every ingested item with its set info
+func (r *RouterOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]loadbalance.NodeStatsOf[T], 0, len(r.items))
+	for _, i := range r.items {
+		info := i.info
+		stats = append(stats, loadbalance.NodeStatsOf[T]{Item: i.item, Weight: i.weight, Set: &info})
+	}
+
+	return stats
+}
+
+// PickContext returns the next selected item of the set info in ctx,
+// or of the default set if not provided
+func (r *RouterOf[T]) PickContext(ctx context.Context) (T, func(balancer.DoneInfo)) {
+	info, ok := FromContext(ctx)
+	if !ok {
+		return r.pickDefault()
+	}
+
+	return r.Pick(info)
+}
+
+// Pick returns the next selected item of the set info,
+// or of the default set if the requested set is empty
+func (r *RouterOf[T]) Pick(info loadbalance.SetInfo) (T, func(balancer.DoneInfo)) {
+	var zero T
+
+	item, done := r.next(info)
+	if item == zero {
+		return r.pickDefault()
+	}
+
+	return item, done
+}
+
+func (r *RouterOf[T]) pickDefault() (T, func(balancer.DoneInfo)) {
+	if r.defaultInfo == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc
+	}
+
+	return r.next(*r.defaultInfo)
+}
+
+// next returns the next selected item of the Set of the set info,
+// and builds the Set if not exists
+func (r *RouterOf[T]) next(info loadbalance.SetInfo) (T, func(balancer.DoneInfo)) {
+	key := newRouterKey(info)
+
+	r.mu.RLock()
+	if s, ok := r.sets[key]; ok {
+		defer r.mu.RUnlock()
+		return s.next()
+	}
+	r.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sets[key]
+	if !ok {
+		if s, ok = r.build(info); !ok {
+			var zero T
+			return zero, internal.EmptyDoneFunc
+		}
+	}
+
+	return s.next()
+}
+
+// build builds and caches the Set of the set info,
+// unless no ingested item belongs to it.
+// NOTE: r.mu must be held
+func (r *RouterOf[T]) build(info loadbalance.SetInfo) (*routerSet[T], bool) {
+	matched := false
+	for _, i := range r.items {
+		if r.setOptions.accepts(info, i.info) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, false
+	}
+
+	s := &routerSet[T]{set: NewOf[T](info, r.newPicker, r.options...)}
+	for _, i := range r.items {
+		s.set.Add(i.item, i.weight, i.info)
+	}
+
+	if len(r.sets) >= r.maxSets {
+		for key, evicted := range r.sets {
+			evicted.set.Reset()
+			delete(r.sets, key)
+			break
+		}
+	}
+	r.sets[newRouterKey(info)] = s
+
+	return s, true
+}
@@ -0,0 +1,220 @@
+package set_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/observer"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/set"
+	"google.golang.org/grpc/balancer"
+	"gotest.tools/assert"
+)
+
+var (
+	unit01 = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01"}
+	unit02 = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "02"}
+	unit03 = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "03"}
+	allBJ  = loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "*"}
+)
+
+func TestRouter(t *testing.T) {
+	t.Run("0 item", func(t *testing.T) {
+		r := set.NewRouter()
+
+		item, done := r.Pick(unit01)
+		done(balancer.DoneInfo{})
+		assert.Equal(t, nil, item)
+
+		item, _ = r.PickContext(context.Background())
+		assert.Equal(t, nil, item)
+	})
+
+	t.Run("pick", func(t *testing.T) {
+		r := set.NewRouter()
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, unit02)
+
+		item, _ := r.Pick(unit01)
+		assert.Equal(t, 1, item)
+
+		item, _ = r.Pick(unit02)
+		assert.Equal(t, 2, item)
+
+		item, _ = r.Pick(unit03)
+		assert.Equal(t, nil, item)
+
+		// items added later go to existing sets too
+		r.Add(3, 1, unit03)
+		item, _ = r.Pick(unit03)
+		assert.Equal(t, 3, item)
+
+		r.Reset()
+		item, _ = r.Pick(unit01)
+		assert.Equal(t, nil, item)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		r := set.NewRouter(set.WithDefault(allBJ))
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, unit02)
+
+		ctx := set.NewContext(context.Background(), unit02)
+		for i := 0; i < 10; i++ {
+			item, _ := r.PickContext(ctx)
+			assert.Equal(t, 2, item)
+		}
+
+		countMap := make(map[interface{}]int)
+		for i := 0; i < 10; i++ {
+			item, _ := r.PickContext(context.Background())
+			countMap[item]++
+		}
+		assert.DeepEqual(t, map[interface{}]int{1: 5, 2: 5}, countMap)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		r := set.NewRouter(set.WithDefault(unit01))
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, unit02)
+
+		item, _ := r.Pick(unit03)
+		assert.Equal(t, 1, item)
+
+		item, _ = r.Pick(unit02)
+		assert.Equal(t, 2, item)
+	})
+
+	t.Run("set options", func(t *testing.T) {
+		selector, err := set.ParseSelector("canary")
+		assert.NilError(t, err)
+
+		r := set.NewRouterOf(p2c.NewLeastLoaded, set.WithSetOptions(set.WithSelector(selector)))
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: "01", Labels: "canary=true"})
+
+		item, done := r.Pick(unit01)
+		done(balancer.DoneInfo{})
+		assert.Equal(t, 2, item)
+	})
+
+	t.Run("max sets", func(t *testing.T) {
+		r := set.NewRouter(set.WithMaxSets(1))
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, unit02)
+
+		for i := 0; i < 3; i++ {
+			item, _ := r.Pick(unit01)
+			assert.Equal(t, 1, item)
+
+			item, _ = r.Pick(unit02)
+			assert.Equal(t, 2, item)
+		}
+
+		// evicted sets are rebuilt with the items added later
+		r.Add(3, 1, unit01)
+		countMap := make(map[interface{}]int)
+		for i := 0; i < 4; i++ {
+			item, _ := r.Pick(unit01)
+			countMap[item]++
+		}
+		assert.DeepEqual(t, map[interface{}]int{1: 2, 3: 2}, countMap)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		r := set.NewRouter(set.WithDefault(allBJ))
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				r.Add(i, 1, loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: fmt.Sprintf("%02d", i)})
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				_, done := r.Pick(loadbalance.SetInfo{Name: "app", Region: "bj", UnitName: fmt.Sprintf("%02d", i)})
+				done(balancer.DoneInfo{})
+			}(i)
+		}
+		wg.Wait()
+
+		item, _ := r.Pick(unit03)
+		assert.Equal(t, 3, item)
+	})
+
+	t.Run("next and stats", func(t *testing.T) {
+		r := set.NewRouter()
+		r.Add(1, 1, unit01)
+		r.Add(2, 2, unit02)
+
+		item, _ := r.Next()
+		assert.Equal(t, nil, item)
+		assert.DeepEqual(t, []loadbalance.NodeStats{
+			{Item: 1, Weight: 1, Set: &unit01},
+			{Item: 2, Weight: 2, Set: &unit02},
+		}, r.Stats())
+
+		r = set.NewRouter(set.WithDefault(unit02))
+		r.Add(1, 1, unit01)
+		r.Add(2, 2, unit02)
+		item, _ = r.Next()
+		assert.Equal(t, 2, item)
+	})
+
+	t.Run("concurrent picks of a set", func(t *testing.T) {
+		r := set.NewRouter()
+		r.Add(1, 1, unit01)
+		r.Add(2, 2, unit01)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					_, done := r.Pick(unit01)
+					done(balancer.DoneInfo{})
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("reset sets", func(t *testing.T) {
+		removed := &removals{}
+		r := set.NewRouterOf(observer.Factory(roundrobin.NewSmoothRoundrobin, removed), set.WithMaxSets(1))
+		r.Add(1, 1, unit01)
+		r.Add(2, 1, unit02)
+
+		// the evicted set is reset
+		r.Pick(unit01)
+		r.Pick(unit02)
+		assert.DeepEqual(t, []interface{}{1}, removed.items)
+
+		r.Reset()
+		assert.DeepEqual(t, []interface{}{1, 2}, removed.items)
+	})
+}
+
+// removals records the removed items
+type removals struct {
+	items []interface{}
+}
+
+func (r *removals) OnAdd(interface{}, float64) {}
+
+func (r *removals) OnPick(interface{}) {}
+
+func (r *removals) OnDone(interface{}, time.Duration, error) {}
+
+func (r *removals) OnEject(interface{}, bool) {}
+
+func (r *removals) OnRemove(item interface{}) {
+	r.items = append(r.items, item)
+}
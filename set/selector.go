@@ -0,0 +1,109 @@
+package set
+
+import (
+	"fmt"
+	"strings"
+)
+
+type operator int
+
+const (
+	opEqual operator = iota
+	opNotEqual
+	opExists
+	opNotExists
+)
+
+// requirement is a single expression of the selector
+type requirement struct {
+	key   string
+	op    operator
+	value string
+}
+
+func (r requirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+
+	switch r.op {
+	case opEqual:
+		return ok && value == r.value
+	case opNotEqual:
+		return !ok || value != r.value
+	case opExists:
+		return ok
+	case opNotExists:
+		return !ok
+	}
+
+	return false
+}
+
+// Selector selects items by their labels,
+// all the requirements must be satisfied
+type Selector []requirement
+
+// ParseSelector parses the comma separated selector expression like
+// `env=prod,canary!=true`, supported requirements are:
+//   - `key=value` or `key==value`, the label must be equal to value
+//   - `key!=value`, the label must be absent or not equal to value
+//   - `key`, the label must be present
+//   - `!key`, the label must be absent
+func ParseSelector(s string) (Selector, error) {
+	selector := make(Selector, 0)
+
+	for _, expr := range strings.Split(s, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+
+		r, err := parseRequirement(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		selector = append(selector, r)
+	}
+
+	return selector, nil
+}
+
+// Matches reports whether the labels satisfy the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseRequirement(expr string) (requirement, error) {
+	var r requirement
+
+	switch {
+	case strings.Contains(expr, "!="):
+		kv := strings.SplitN(expr, "!=", 2)
+		r = requirement{key: kv[0], op: opNotEqual, value: kv[1]}
+	case strings.Contains(expr, "=="):
+		kv := strings.SplitN(expr, "==", 2)
+		r = requirement{key: kv[0], op: opEqual, value: kv[1]}
+	case strings.Contains(expr, "="):
+		kv := strings.SplitN(expr, "=", 2)
+		r = requirement{key: kv[0], op: opEqual, value: kv[1]}
+	case strings.HasPrefix(expr, "!"):
+		r = requirement{key: expr[1:], op: opNotExists}
+	default:
+		r = requirement{key: expr, op: opExists}
+	}
+
+	r.key = strings.TrimSpace(r.key)
+	r.value = strings.TrimSpace(r.value)
+
+	if r.key == "" || strings.ContainsAny(r.key, "!=") || strings.ContainsAny(r.value, "!=") {
+		return requirement{}, fmt.Errorf("set: invalid selector requirement %q", expr)
+	}
+
+	return r, nil
+}
@@ -2,44 +2,112 @@ package set
 
 import (
 	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/p2c"
 	"github.com/hnlq715/go-loadbalance/roundrobin"
 	"google.golang.org/grpc/balancer"
 )
 
-type Set struct {
+type SetOf[T any] struct {
 	info   loadbalance.SetInfo
-	picker loadbalance.Picker
+	picker loadbalance.PickerOf[T]
+	options
 }
 
-func New(info loadbalance.SetInfo) loadbalance.Set {
-	return &Set{
-		info:   info,
-		picker: roundrobin.NewSmoothRoundrobin(),
+// Set is a SetOf items of any type
+type Set = SetOf[interface{}]
+
+type options struct {
+	matcher  Matcher
+	selector Selector
+}
+
+// Option configures the Set
+type Option func(*options)
+
+// WithMatcher replaces the default Match with a custom Matcher
+func WithMatcher(matcher Matcher) Option {
+	return func(o *options) {
+		o.matcher = matcher
 	}
 }
 
-func (s *Set) Next() (interface{}, func(balancer.DoneInfo)) {
-	return s.picker.Next()
+// WithSelector only accepts items with labels satisfy the selector
+func WithSelector(selector Selector) Option {
+	return func(o *options) {
+		o.selector = selector
+	}
 }
 
-func (s *Set) Add(item interface{}, weigth float64, info loadbalance.SetInfo) {
-	if info.Name != s.info.Name {
-		return
+// NewLeastLoaded returns a Set with least loaded p2c
+func NewLeastLoaded(info loadbalance.SetInfo, opts ...Option) loadbalance.Set {
+	return NewOf(info, p2c.NewLeastLoaded, opts...)
+}
+
+// NewPeakEwma returns a Set with pewma p2c
+func NewPeakEwma(info loadbalance.SetInfo, opts ...Option) loadbalance.Set {
+	return NewOf(info, p2c.NewPeakEwma, opts...)
+}
+
+// NewSmoothRoundrobin returns a Set with smooth roundrobin
+func NewSmoothRoundrobin(info loadbalance.SetInfo, opts ...Option) loadbalance.Set {
+	return NewOf(info, roundrobin.NewSmoothRoundrobin, opts...)
+}
+
+// New returns a Set with smooth roundrobin
+func New(info loadbalance.SetInfo, opts ...Option) loadbalance.Set {
+	return NewOf[interface{}](info, nil, opts...)
+}
+
+// NewOf returns a SetOf with the inner Picker created by factory,
+// the smooth roundrobin Picker is used if nil
+func NewOf[T any](info loadbalance.SetInfo, factory loadbalance.PickerFactoryOf[T], opts ...Option) loadbalance.SetOf[T] {
+	if factory == nil {
+		factory = roundrobin.NewSmoothRoundrobinOf[T]
 	}
 
-	if info.Region != s.info.Region {
-		return
+	s := &SetOf[T]{
+		info:    info,
+		picker:  factory(),
+		options: options{matcher: Match},
 	}
 
-	if s.info.UnitName != "*" {
-		if info.UnitName != s.info.UnitName {
-			return
-		}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+
+	return s
+}
+
+// accepts reports whether the item with set info belongs to the target set
+func (o *options) accepts(target, info loadbalance.SetInfo) bool {
+	return o.matcher(target, info) && o.selector.Matches(info.Labels.Map())
+}
+
+func (s *SetOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	return s.picker.Next()
+}
+
+func (s *SetOf[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	return loadbalance.NextErr[T](s.picker)
+}
+
+func (s *SetOf[T]) Add(item T, weigth float64, info loadbalance.SetInfo) {
+	if !s.accepts(s.info, info) {
+		return
 	}
 
 	s.picker.Add(item, weigth)
 }
 
-func (s *Set) Reset() {
+// Info returns the set info of this Set
+func (s *SetOf[T]) Info() loadbalance.SetInfo {
+	return s.info
+}
+
+func (s *SetOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	return loadbalance.Stats[T](s.picker)
+}
+
+func (s *SetOf[T]) Reset() {
 	s.picker.Reset()
 }
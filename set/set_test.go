@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/p2c"
 	"github.com/hnlq715/go-loadbalance/set"
+	"google.golang.org/grpc/balancer"
 	"gotest.tools/assert"
 )
 
@@ -107,4 +109,126 @@ func TestSet(t *testing.T) {
 		item, _ = s.Next()
 		assert.Equal(t, 2, item)
 	})
+
+	t.Run("glob", func(t *testing.T) {
+		s := set.New(loadbalance.SetInfo{
+			Name:     "app-*",
+			Region:   "bj",
+			UnitName: "gz.01.*",
+		})
+
+		s.Add(1, 1, loadbalance.SetInfo{
+			Name:     "app-web",
+			Region:   "bj",
+			UnitName: "gz.01.a",
+		})
+
+		s.Add(2, 1, loadbalance.SetInfo{
+			Name:     "app-web",
+			Region:   "bj",
+			UnitName: "gz.02.a",
+		})
+
+		item, _ := s.Next()
+		assert.Equal(t, 1, item)
+
+		item, _ = s.Next()
+		assert.Equal(t, 1, item)
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		selector, err := set.ParseSelector("env=prod,canary!=true")
+		assert.NilError(t, err)
+
+		s := set.New(loadbalance.SetInfo{
+			Name:     "app",
+			Region:   "bj",
+			UnitName: "*",
+		}, set.WithSelector(selector))
+
+		s.Add(1, 1, loadbalance.SetInfo{
+			Name:   "app",
+			Region: "bj",
+			Labels: loadbalance.NewLabels(map[string]string{"env": "prod", "canary": "true"}),
+		})
+
+		s.Add(2, 1, loadbalance.SetInfo{
+			Name:   "app",
+			Region: "bj",
+			Labels: "env=prod",
+		})
+
+		item, _ := s.Next()
+		assert.Equal(t, 2, item)
+
+		item, _ = s.Next()
+		assert.Equal(t, 2, item)
+	})
+
+	t.Run("matcher", func(t *testing.T) {
+		s := set.New(loadbalance.SetInfo{
+			Name: "app",
+		}, set.WithMatcher(func(target, info loadbalance.SetInfo) bool {
+			return target.Name == info.Name
+		}))
+
+		s.Add(1, 1, loadbalance.SetInfo{
+			Name:   "app",
+			Region: "sh",
+		})
+
+		item, _ := s.Next()
+		assert.Equal(t, 1, item)
+	})
+
+	t.Run("picker", func(t *testing.T) {
+		info := loadbalance.SetInfo{
+			Name:     "app",
+			Region:   "bj",
+			UnitName: "01",
+		}
+
+		for _, s := range []loadbalance.Set{
+			set.NewLeastLoaded(info),
+			set.NewPeakEwma(info),
+			set.NewSmoothRoundrobin(info),
+			set.NewOf(info, p2c.NewLeastLoaded),
+		} {
+			s.Add(1, 1, info)
+			s.Add(2, 1, loadbalance.SetInfo{Name: "app", Region: "sh", UnitName: "01"})
+
+			item, done := s.Next()
+			done(balancer.DoneInfo{})
+			assert.Equal(t, 1, item)
+
+			s.Reset()
+			item, _ = s.Next()
+			assert.Equal(t, nil, item)
+		}
+	})
+}
+
+func TestSetOf(t *testing.T) {
+	info := loadbalance.SetInfo{
+		Name:     "app",
+		Region:   "bj",
+		UnitName: "01",
+	}
+
+	for _, s := range []loadbalance.SetOf[string]{
+		set.NewOf[string](info, nil),
+		set.NewOf(info, p2c.NewLeastLoadedOf[string]),
+		set.NewOf(info, p2c.NewPeakEwmaOf[string]),
+	} {
+		s.Add("10.0.0.1:80", 1, info)
+		s.Add("10.0.0.2:80", 1, loadbalance.SetInfo{Name: "app", Region: "sh", UnitName: "01"})
+
+		item, done := s.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "10.0.0.1:80", item)
+
+		s.Reset()
+		item, _ = s.Next()
+		assert.Equal(t, "", item)
+	}
 }
@@ -0,0 +1,213 @@
+package split
+
+import (
+	"sync"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"google.golang.org/grpc/balancer"
+)
+
+type child[T any] struct {
+	name    string
+	picker  loadbalance.NexterOf[T]
+	weight  float64
+	current float64
+	picks   uint64
+}
+
+// SplitOf splits traffic across child pickers by weights,
+// like 95% to the stable set and 5% to the canary set.
+// The child is selected by smooth weighted roundrobin,
+// so the observed ratios are accurate even for small amount of traffic.
+type SplitOf[T any] struct {
+	children []*child[T]
+	picks    uint64
+	mu       sync.Mutex
+}
+
+// Split is a SplitOf items of any type
+type Split = SplitOf[interface{}]
+
+// New returns a Split picker
+func New() *Split {
+	return NewOf[interface{}]()
+}
+
+// NewOf returns a SplitOf picker
+func NewOf[T any]() *SplitOf[T] {
+	return &SplitOf[T]{
+		children: make([]*child[T], 0),
+	}
+}
+
+// Add a named child picker with weight, replaces the child with the same name
+func (s *SplitOf[T]) Add(name string, picker loadbalance.NexterOf[T], weight float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if weight < 0 {
+		weight = 0
+	}
+
+	for _, c := range s.children {
+		if c.name == name {
+			c.picker = picker
+			c.weight = weight
+			s.restart()
+			return
+		}
+	}
+
+	s.children = append(s.children, &child[T]{name: name, picker: picker, weight: weight})
+	s.restart()
+}
+
+// SetWeight updates the weight of the named child without touching its picker,
+// returns false if the child doesn't exist
+func (s *SplitOf[T]) SetWeight(name string, weight float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if weight < 0 {
+		weight = 0
+	}
+
+	for _, c := range s.children {
+		if c.name == name {
+			c.weight = weight
+			s.restart()
+			return true
+		}
+	}
+
+	return false
+}
+
+// Weights returns the configured ratio of each child
+func (s *SplitOf[T]) Weights() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := float64(0)
+	for _, c := range s.children {
+		total += c.weight
+	}
+
+	weights := make(map[string]float64, len(s.children))
+	for _, c := range s.children {
+		if total > 0 {
+			weights[c.name] = c.weight / total
+		} else {
+			weights[c.name] = 0
+		}
+	}
+
+	return weights
+}
+
+// Observed returns the observed ratio of each child since last ResetObserved
+func (s *SplitOf[T]) Observed() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	observed := make(map[string]float64, len(s.children))
+	for _, c := range s.children {
+		if s.picks > 0 {
+			observed[c.name] = float64(c.picks) / float64(s.picks)
+		} else {
+			observed[c.name] = 0
+		}
+	}
+
+	return observed
+}
+
+// ResetObserved clears the observed picks
+func (s *SplitOf[T]) ResetObserved() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.picks = 0
+	for _, c := range s.children {
+		c.picks = 0
+	}
+}
+
+// Stats returns the state of every item of all children
+func (s *SplitOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]loadbalance.NodeStatsOf[T], 0)
+	for _, c := range s.children {
+		stats = append(stats, loadbalance.Stats[T](c.picker)...)
+	}
+
+	return stats
+}
+
+// Reset removes all children
+func (s *SplitOf[T]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.children = s.children[:0]
+	s.picks = 0
+}
+
+// Next returns the next selected item of the selected child
+func (s *SplitOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := s.NextErr()
+	return item, done
+}
+
+// NextErr returns the next selected item of the selected child,
+// or ErrNoAvailableItem if no child has weight
+func (s *SplitOf[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	s.mu.Lock()
+	best := s.nextChild()
+	if best != nil {
+		best.picks++
+		s.picks++
+	}
+	s.mu.Unlock()
+
+	if best == nil {
+		var zero T
+		return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
+	}
+
+	return loadbalance.NextErr[T](best.picker)
+}
+
+// nextChild selects the child through the smooth weighted roundrobin
+func (s *SplitOf[T]) nextChild() (best *child[T]) {
+	total := float64(0)
+
+	for _, c := range s.children {
+		if c.weight <= 0 {
+			continue
+		}
+
+		c.current += c.weight
+		total += c.weight
+
+		if best == nil || c.current > best.current {
+			best = c
+		}
+	}
+
+	if best != nil {
+		best.current -= total
+	}
+
+	return best
+}
+
+// restart restarts the smooth weighted sequence after weights changed
+func (s *SplitOf[T]) restart() {
+	for _, c := range s.children {
+		c.current = 0
+	}
+}
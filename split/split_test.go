@@ -0,0 +1,104 @@
+package split_test
+
+import (
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/hnlq715/go-loadbalance/split"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+func TestSplit(t *testing.T) {
+	t.Run("0 item", func(t *testing.T) {
+		s := split.New()
+		item, done := s.Next()
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+
+		s.Add("zero", roundrobin.NewSmoothRoundrobin(), 0)
+		item, _ = s.Next()
+		assert.Nil(t, item)
+		assert.Equal(t, map[string]float64{"zero": 0}, s.Observed())
+	})
+
+	t.Run("canary", func(t *testing.T) {
+		stable := roundrobin.NewSmoothRoundrobin()
+		stable.Add("stable1", 1)
+		stable.Add("stable2", 1)
+
+		canary := p2c.NewLeastLoaded()
+		canary.Add("canary", 1)
+
+		s := split.New()
+		s.Add("stable", stable, 95)
+		s.Add("canary", canary, 5)
+		assert.Equal(t, map[string]float64{"stable": 0.95, "canary": 0.05}, s.Weights())
+
+		countMap := make(map[interface{}]int)
+		for i := 0; i < 1000; i++ {
+			item, done := s.Next()
+			done(balancer.DoneInfo{})
+			countMap[item]++
+		}
+
+		assert.Equal(t, map[interface{}]int{"stable1": 475, "stable2": 475, "canary": 50}, countMap)
+		assert.Equal(t, map[string]float64{"stable": 0.95, "canary": 0.05}, s.Observed())
+	})
+
+	t.Run("reweight", func(t *testing.T) {
+		stable := roundrobin.NewSmoothRoundrobin()
+		stable.Add("stable1", 1)
+		stable.Add("stable2", 1)
+
+		canary := roundrobin.NewSmoothRoundrobin()
+		canary.Add("canary", 1)
+
+		s := split.New()
+		s.Add("stable", stable, 90)
+		s.Add("canary", canary, 10)
+
+		// stable1 is picked once, the next one of stable is stable2
+		item, _ := s.Next()
+		assert.Equal(t, "stable1", item)
+
+		assert.True(t, s.SetWeight("stable", 50))
+		assert.True(t, s.SetWeight("canary", 50))
+		assert.False(t, s.SetWeight("unknown", 50))
+
+		item, _ = s.Next()
+		assert.Equal(t, "stable2", item)
+
+		s.ResetObserved()
+		for i := 0; i < 100; i++ {
+			s.Next()
+		}
+		assert.Equal(t, map[string]float64{"stable": 0.5, "canary": 0.5}, s.Observed())
+
+		s.SetWeight("stable", 0)
+		for i := 0; i < 10; i++ {
+			item, _ := s.Next()
+			assert.Equal(t, "canary", item)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		old := roundrobin.NewSmoothRoundrobin()
+		old.Add("old", 1)
+
+		latest := roundrobin.NewSmoothRoundrobin()
+		latest.Add("new", 1)
+
+		s := split.New()
+		s.Add("stable", old, 1)
+		s.Add("stable", latest, 1)
+
+		item, _ := s.Next()
+		assert.Equal(t, "new", item)
+
+		s.Reset()
+		item, _ = s.Next()
+		assert.Nil(t, item)
+	})
+}
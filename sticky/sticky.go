@@ -0,0 +1,302 @@
+package sticky
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// defaultCapacity is the max number of session mappings
+	defaultCapacity int = 4096
+	// defaultTTL expires the session mapping after idle for a while
+	defaultTTL = 10 * time.Minute
+	// defaultMetadataKey is the metadata carrying the session key
+	defaultMetadataKey = "x-session-id"
+)
+
+type sessionKey struct{}
+
+// NewContext returns a new context carrying the session key
+func NewContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, key)
+}
+
+// FromContext returns the session key in ctx if any
+func FromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(sessionKey{}).(string)
+	return key, ok
+}
+
+type session[T comparable] struct {
+	key    string
+	item   T
+	expire time.Time
+}
+
+// StickyOf pins a session to the item picked by the inner Picker at first,
+// and re-pins it when the item is gone.
+// Pinned picks are accounted by the inner Picker if it's a TrackerOf,
+// otherwise their done functions are empty, which only suits stateless pickers.
+// Session mappings are kept in a bounded LRU and expire after idle for TTL.
+// Items must be comparable since they are used as map keys,
+// and the zero value of T means no item is picked.
+type StickyOf[T comparable] struct {
+	picker loadbalance.PickerOf[T]
+	items  map[T]struct{}
+	options
+	now func() time.Time
+
+	sessions map[string]*list.Element
+	lru      *list.List
+	mu       sync.Mutex
+}
+
+// Sticky is a StickyOf items of any type
+type Sticky = StickyOf[interface{}]
+
+type options struct {
+	capacity    int
+	ttl         time.Duration
+	metadataKey string
+}
+
+// Option configures the Sticky picker
+type Option func(*options)
+
+// WithCapacity sets the max number of session mappings
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		if capacity > 0 {
+			o.capacity = capacity
+		}
+	}
+}
+
+// WithTTL sets the idle time after which the session mapping expires
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithMetadataKey sets the outgoing metadata carrying the session key
+func WithMetadataKey(key string) Option {
+	return func(o *options) {
+		o.metadataKey = key
+	}
+}
+
+// New returns a Sticky picker falls back to the inner picker for new sessions
+func New(picker loadbalance.Picker, opts ...Option) *Sticky {
+	return NewOf(picker, opts...)
+}
+
+// NewOf returns a StickyOf picker falls back to the inner picker for new sessions
+func NewOf[T comparable](picker loadbalance.PickerOf[T], opts ...Option) *StickyOf[T] {
+	s := &StickyOf[T]{
+		picker: picker,
+		items:  make(map[T]struct{}),
+		options: options{
+			capacity:    defaultCapacity,
+			ttl:         defaultTTL,
+			metadataKey: defaultMetadataKey,
+		},
+		now:      time.Now,
+		sessions: make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+
+	return s
+}
+
+var (
+	_ loadbalance.Picker  = (*Sticky)(nil)
+	_ loadbalance.Updater = (*Sticky)(nil)
+)
+
+// Add a weighted item.
+func (s *StickyOf[T]) Add(item T, weight float64) {
+	s.mu.Lock()
+	s.items[item] = struct{}{}
+	s.mu.Unlock()
+
+	s.picker.Add(item, weight)
+}
+
+// Reset this picker, sessions are re-pinned if their items are not added back
+func (s *StickyOf[T]) Reset() {
+	s.mu.Lock()
+	s.items = make(map[T]struct{})
+	s.mu.Unlock()
+
+	s.picker.Reset()
+}
+
+// Remove the item if the inner picker is an Updater,
+// sessions pinned to it are re-pinned on their next pick
+func (s *StickyOf[T]) Remove(item T) bool {
+	updater, ok := s.picker.(loadbalance.UpdaterOf[T])
+	if !ok || !updater.Remove(item) {
+		return false
+	}
+
+	s.mu.Lock()
+	delete(s.items, item)
+	s.mu.Unlock()
+
+	return true
+}
+
+// UpdateWeight of the item if the inner picker is an Updater
+func (s *StickyOf[T]) UpdateWeight(item T, weight float64) bool {
+	updater, ok := s.picker.(loadbalance.UpdaterOf[T])
+	if !ok {
+		return false
+	}
+
+	return updater.UpdateWeight(item, weight)
+}
+
+// Stats returns the state of every item of the inner picker
+func (s *StickyOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	return loadbalance.Stats[T](s.picker)
+}
+
+// Len returns the number of session mappings
+func (s *StickyOf[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lru.Len()
+}
+
+// Next returns the next selected item of the inner picker without session
+func (s *StickyOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	return s.picker.Next()
+}
+
+// NextErr returns the next selected item of the inner picker without session,
+// or an error if nothing is picked
+func (s *StickyOf[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	return loadbalance.NextErr[T](s.picker)
+}
+
+// NextContext returns the item pinned by the session key in ctx,
+// which is set by NewContext or carried by the outgoing metadata
+func (s *StickyOf[T]) NextContext(ctx context.Context) (T, func(balancer.DoneInfo)) {
+	if key, ok := FromContext(ctx); ok {
+		return s.NextKey(key)
+	}
+
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(s.metadataKey); len(values) > 0 {
+			return s.NextKey(values[0])
+		}
+	}
+
+	return s.Next()
+}
+
+// NextKey returns the item pinned by the session key,
+// the pinned item is tracked by the inner picker if it's a TrackerOf
+func (s *StickyOf[T]) NextKey(key string) (T, func(balancer.DoneInfo)) {
+	if key == "" {
+		return s.Next()
+	}
+
+	now := s.now()
+
+	if item, ok := s.pinned(key, now); ok {
+		if done, ok := s.track(item); ok {
+			return item, done
+		}
+
+		// the item is gone from the inner picker
+		s.mu.Lock()
+		s.unpin(key, item)
+		s.mu.Unlock()
+	}
+
+	item, done := s.picker.Next()
+	var zero T
+	if item == zero {
+		return item, done
+	}
+
+	s.mu.Lock()
+	s.pin(key, item, now)
+	s.mu.Unlock()
+
+	return item, done
+}
+
+// pinned returns the alive item pinned by the session key, and refreshes its TTL
+func (s *StickyOf[T]) pinned(key string, now time.Time) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero T
+	elem, ok := s.sessions[key]
+	if !ok {
+		return zero, false
+	}
+
+	sess := elem.Value.(*session[T])
+	if _, alive := s.items[sess.item]; !alive || !now.Before(sess.expire) {
+		s.remove(elem)
+		return zero, false
+	}
+
+	sess.expire = now.Add(s.ttl)
+	s.lru.MoveToFront(elem)
+
+	return sess.item, true
+}
+
+// track accounts the pinned item in the inner picker if it's a TrackerOf
+func (s *StickyOf[T]) track(item T) (func(balancer.DoneInfo), bool) {
+	tracker, ok := s.picker.(loadbalance.TrackerOf[T])
+	if !ok {
+		return internal.EmptyDoneFunc, true
+	}
+
+	return tracker.Track(item)
+}
+
+// unpin removes the session mapping if it's still pinned to the item
+func (s *StickyOf[T]) unpin(key string, item T) {
+	if elem, ok := s.sessions[key]; ok && elem.Value.(*session[T]).item == item {
+		s.remove(elem)
+	}
+}
+
+// pin maps the session key to the item, and evicts the least recently used one
+func (s *StickyOf[T]) pin(key string, item T, now time.Time) {
+	if elem, ok := s.sessions[key]; ok {
+		s.remove(elem)
+	}
+
+	s.sessions[key] = s.lru.PushFront(&session[T]{key: key, item: item, expire: now.Add(s.ttl)})
+
+	for s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *StickyOf[T]) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.sessions, elem.Value.(*session[T]).key)
+}
@@ -0,0 +1,218 @@
+package sticky
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSticky(t *testing.T) {
+	t.Run("0 item", func(t *testing.T) {
+		s := New(p2c.NewLeastLoaded())
+		item, done := s.NextKey("user1")
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+		assert.Equal(t, 0, s.Len())
+	})
+
+	t.Run("pin", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin())
+		s.Add(1, 1)
+		s.Add(2, 1)
+		s.Add(3, 1)
+
+		pinned := make(map[string]interface{})
+		for i := 0; i < 3; i++ {
+			key := fmt.Sprintf("user%d", i)
+			pinned[key], _ = s.NextKey(key)
+		}
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("user%d", i%3)
+			item, done := s.NextKey(key)
+			done(balancer.DoneInfo{})
+			assert.Equal(t, pinned[key], item)
+		}
+
+		assert.Equal(t, 3, s.Len())
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin())
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("")
+		assert.Equal(t, 1, item)
+		item, _ = s.Next()
+		assert.Equal(t, 2, item)
+		item, _ = s.NextContext(context.Background())
+		assert.Equal(t, 1, item)
+		assert.Equal(t, 0, s.Len())
+	})
+
+	t.Run("context", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin(), WithMetadataKey("x-user"))
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		ctx := NewContext(context.Background(), "user1")
+		first, _ := s.NextContext(ctx)
+		for i := 0; i < 10; i++ {
+			item, _ := s.NextContext(ctx)
+			assert.Equal(t, first, item)
+		}
+
+		ctx = metadata.AppendToOutgoingContext(context.Background(), "x-user", "user1")
+		item, _ := s.NextContext(ctx)
+		assert.Equal(t, first, item)
+
+		ctx = metadata.AppendToOutgoingContext(context.Background(), "x-session-id", "user1")
+		item, _ = s.NextContext(ctx)
+		assert.NotEqual(t, first, item)
+	})
+
+	t.Run("re-pin", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin())
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		// 1 is removed
+		s.Reset()
+		s.Add(2, 1)
+		s.Add(3, 1)
+
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 2, item)
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 2, item)
+		assert.Equal(t, 1, s.Len())
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin())
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		assert.True(t, s.Remove(1))
+		assert.False(t, s.Remove(1))
+		assert.True(t, s.UpdateWeight(2, 3))
+
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 2, item)
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 2, item)
+		assert.Equal(t, 1, s.Len())
+	})
+
+	t.Run("track", func(t *testing.T) {
+		s := New(p2c.NewLeastLoaded())
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("user1")
+
+		// pinned picks are accounted by the inner picker
+		dones := make([]func(balancer.DoneInfo), 0)
+		for i := 0; i < 3; i++ {
+			_, done := s.NextKey("user1")
+			dones = append(dones, done)
+		}
+		for _, stats := range s.Stats() {
+			if stats.Item == item {
+				assert.Equal(t, int64(4), stats.Inflight)
+			}
+		}
+
+		for _, done := range dones {
+			done(balancer.DoneInfo{})
+		}
+		for _, stats := range s.Stats() {
+			if stats.Item == item {
+				assert.Equal(t, int64(1), stats.Inflight)
+			}
+		}
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		s := New(roundrobin.NewSmoothRoundrobin(), WithCapacity(2))
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("user1")
+		assert.Equal(t, 1, item)
+		item, _ = s.NextKey("user2")
+		assert.Equal(t, 2, item)
+
+		// user1 is the most recently used
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		// user2 is evicted
+		item, _ = s.NextKey("user3")
+		assert.Equal(t, 1, item)
+		assert.Equal(t, 2, s.Len())
+
+		item, _ = s.NextKey("user2")
+		assert.Equal(t, 2, item)
+		item, _ = s.NextKey("user3")
+		assert.Equal(t, 1, item)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := time.Now()
+		s := New(roundrobin.NewSmoothRoundrobin(), WithTTL(time.Minute))
+		s.now = func() time.Time { return now }
+		s.Add(1, 1)
+		s.Add(2, 1)
+
+		item, _ := s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		// idle time is refreshed on every access
+		now = now.Add(50 * time.Second)
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		now = now.Add(50 * time.Second)
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 1, item)
+
+		now = now.Add(time.Minute)
+		item, _ = s.NextKey("user1")
+		assert.Equal(t, 2, item)
+		assert.Equal(t, 1, s.Len())
+	})
+}
+
+func TestStickyOf(t *testing.T) {
+	s := NewOf(roundrobin.NewSmoothRoundrobinOf[string]())
+
+	// the zero value is not pinned
+	item, _ := s.NextKey("user1")
+	assert.Equal(t, "", item)
+	assert.Equal(t, 0, s.Len())
+
+	s.Add("10.0.0.1:80", 1)
+	s.Add("10.0.0.2:80", 1)
+
+	pinned, _ := s.NextKey("user1")
+	for i := 0; i < 10; i++ {
+		item, _ := s.NextKey("user1")
+		assert.Equal(t, pinned, item)
+	}
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,41 @@
+package subset
+
+import (
+	"math/rand"
+
+	"github.com/hnlq715/go-loadbalance"
+)
+
+// NewDeterministic returns a Subsetter interface with least loaded p2c,
+// which is the deterministic subsetting described in the SRE book:
+// https://sre.google/sre-book/load-balancing-datacenter/#a-subset-selection-algorithm-deterministic-subsetting-eKsdcJ
+//
+// Local peers are grouped into rounds, every round shuffles remote peers
+// with the round as seed and assigns a distinct subset to each local peer,
+// so every remote peer gets the same number of connections in a round.
+func NewDeterministic() loadbalance.Subsetter {
+	return NewDeterministicOf[interface{}]()
+}
+
+// NewDeterministicOf returns a SubsetterOf interface with least loaded p2c,
+// which is the deterministic subsetting of remote peers of type T
+func NewDeterministicOf[T any]() loadbalance.SubsetterOf[T] {
+	return newSubsetter(deterministic[T])
+}
+
+func deterministic[T any](_ []string, localIdx int, remotePeers []T, size int) []int {
+	if size > len(remotePeers) {
+		size = len(remotePeers)
+	}
+
+	subsetCount := len(remotePeers) / size
+	round := localIdx / subsetCount
+
+	// the same shuffle for all local peers in the same round
+	idxes := rand.New(rand.NewSource(int64(round))).Perm(len(remotePeers))
+
+	subsetID := localIdx % subsetCount
+	start := subsetID * size
+
+	return idxes[start : start+size]
+}
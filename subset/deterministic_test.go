@@ -0,0 +1,54 @@
+package subset_test
+
+import (
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance/subset"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeterministic(t *testing.T) {
+	testSubsetter(t, subset.NewDeterministic)
+
+	t.Run("balance", func(t *testing.T) {
+		localPeers := names("local", 40)
+		remotePeers := peers(names("remote", 12))
+
+		s := subsets(subset.NewDeterministic, localPeers, remotePeers, 3)
+		for _, subset := range s {
+			assert.Equal(t, 3, len(subset))
+		}
+
+		// 4 subsets in a round and 10 rounds
+		for peer, conns := range connections(s, remotePeers) {
+			assert.Equal(t, 10, conns, peer)
+		}
+
+		// distinct subsets in the same round
+		for round := 0; round < 10; round++ {
+			seen := make(map[interface{}]bool)
+			for _, subset := range s[round*4 : round*4+4] {
+				for _, peer := range subset {
+					assert.False(t, seen[peer])
+					seen[peer] = true
+				}
+			}
+		}
+	})
+
+	t.Run("churn", func(t *testing.T) {
+		localPeers := names("local", 300)
+		remoteNames := names("remote", 100)
+		before := subsets(subset.NewDeterministic, localPeers, peers(remoteNames), 10)
+
+		added := subsets(subset.NewDeterministic, localPeers, peers(append(remoteNames, "remote-100")), 10)
+		changedPeers, changedConns := churn(before, added)
+		t.Logf("add a remote peer: %d/%d local peers changed, %d connections changed", changedPeers, len(localPeers), changedConns)
+		assert.Less(t, 0, changedPeers)
+
+		removed := subsets(subset.NewDeterministic, localPeers, peers(remoteNames[1:]), 10)
+		changedPeers, changedConns = churn(before, removed)
+		t.Logf("remove a remote peer: %d/%d local peers changed, %d connections changed", changedPeers, len(localPeers), changedConns)
+		assert.Less(t, 0, changedPeers)
+	})
+}
@@ -0,0 +1,149 @@
+package subset
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hnlq715/go-loadbalance"
+)
+
+// defaultLoadFactor bounds the connections of every remote peer
+// to the load factor times the average
+const defaultLoadFactor float64 = 1.25
+
+// NewRocksteady returns a Subsetter interface with least loaded p2c,
+// which selects the remote peers with the highest rendezvous hash scores
+// for the local peer, remote peers are identified by fmt.Sprint like addresses.
+//
+// Subsets barely change on scale events, when a single remote peer is
+// added or removed, mostly the local peers with it in their subsets change,
+// which are about subset size / remote peers of all local peers.
+//
+// Connections are bounded by load like consistent hashing with bounded loads:
+// local peers take their subsets in order of their ids, and skip the remote
+// peers already connected by load factor times the average of local peers.
+func NewRocksteady() loadbalance.Subsetter {
+	return NewRocksteadyOf[interface{}](nil)
+}
+
+// NewRocksteadyOf returns a SubsetterOf interface with least loaded p2c,
+// which is the rendezvous hashing subsetting of remote peers of type T.
+// Remote peers are identified by key, which must be the same on every
+// local peer like the address, fmt.Sprint is used if nil.
+func NewRocksteadyOf[T any](key loadbalance.KeyFunc[T]) loadbalance.SubsetterOf[T] {
+	if key == nil {
+		key = func(peer T) string { return fmt.Sprint(peer) }
+	}
+
+	return newSubsetter(rocksteady(key, defaultLoadFactor))
+}
+
+type score struct {
+	idx   int
+	score uint64
+}
+
+// higher reports whether s ranks before o, ties are broken by index
+func (s score) higher(o score) bool {
+	if s.score == o.score {
+		return s.idx < o.idx
+	}
+
+	return s.score > o.score
+}
+
+func rocksteady[T any](key loadbalance.KeyFunc[T], loadFactor float64) strategy[T] {
+	return func(localPeers []string, localIdx int, remotePeers []T, size int) []int {
+		if size > len(remotePeers) {
+			size = len(remotePeers)
+		}
+
+		remoteKeys := make([]string, 0, len(remotePeers))
+		for _, peer := range remotePeers {
+			remoteKeys = append(remoteKeys, key(peer))
+		}
+
+		average := float64(len(localPeers)*size) / float64(len(remotePeers))
+		capacity := int(math.Ceil(average * loadFactor))
+
+		// local peers take their subsets in order of their ids,
+		// so every local peer gets the same connections of the others
+		localID := localPeers[localIdx]
+		ordered := append([]string(nil), localPeers...)
+		sort.Strings(ordered)
+
+		conns := make([]int, len(remotePeers))
+		for _, local := range ordered {
+			idxes := topScores(local, remoteKeys, conns, capacity, size)
+			if local == localID {
+				sort.Ints(idxes)
+				return idxes
+			}
+
+			for _, idx := range idxes {
+				conns[idx]++
+			}
+		}
+
+		return nil
+	}
+}
+
+// topScores returns the size remote peers with the highest scores for the local peer,
+// which are not connected by capacity local peers yet
+func topScores(local string, remoteKeys []string, conns []int, capacity, size int) []int {
+	scores := make([]score, 0, len(remoteKeys))
+	for idx, remote := range remoteKeys {
+		scores = append(scores, score{idx: idx, score: hash(local, remote)})
+	}
+
+	// selects the highest one repeatedly, which is cheaper than sorting for small subsets
+	idxes := make([]int, 0, size)
+	for len(idxes) < size {
+		best := -1
+		for i, s := range scores {
+			if conns[s.idx] < capacity && (best < 0 || s.higher(scores[best])) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		idxes = append(idxes, scores[best].idx)
+		scores[best] = scores[len(scores)-1]
+		scores = scores[:len(scores)-1]
+	}
+
+	return idxes
+}
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// hash returns the rendezvous hash score of the local and remote peer,
+// which is fnv-1a of `local\x00remote` without allocations
+func hash(local, remote string) uint64 {
+	x := fnvOffset64
+	for i := 0; i < len(local); i++ {
+		x ^= uint64(local[i])
+		x *= fnvPrime64
+	}
+	x *= fnvPrime64
+	for i := 0; i < len(remote); i++ {
+		x ^= uint64(remote[i])
+		x *= fnvPrime64
+	}
+
+	// fnv is weak for similar keys, mixes it by the splitmix64 finalizer
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,104 @@
+package subset_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance/subset"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRocksteady(t *testing.T) {
+	testSubsetter(t, subset.NewRocksteady)
+
+	localPeers := names("local", 300)
+	remoteNames := names("remote", 100)
+	before := subsets(subset.NewRocksteady, localPeers, peers(remoteNames), 10)
+
+	t.Run("balance", func(t *testing.T) {
+		for _, subset := range before {
+			assert.Equal(t, 10, len(subset))
+		}
+
+		// 30 connections for every remote peer on average, bounded by 1.25 times of it,
+		// the lower tail is left to the hash since underloaded peers are harmless
+		for peer, conns := range connections(before, peers(remoteNames)) {
+			assert.True(t, conns >= 15 && conns <= 38, "%v: %d", peer, conns)
+		}
+	})
+
+	t.Run("add", func(t *testing.T) {
+		after := subsets(subset.NewRocksteady, localPeers, peers(append(remoteNames, "remote-100")), 10)
+		changedPeers, changedConns := churn(before, after)
+		t.Logf("add a remote peer: %d/%d local peers changed, %d connections changed", changedPeers, len(localPeers), changedConns)
+
+		// about 300 * 10 / 101 local peers, each closes one and opens one connection
+		assert.True(t, changedPeers > 0 && changedPeers <= 60, changedPeers)
+		assert.Equal(t, 2*changedPeers, changedConns)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		after := subsets(subset.NewRocksteady, localPeers, peers(remoteNames[1:]), 10)
+		changedPeers, changedConns := churn(before, after)
+		t.Logf("remove a remote peer: %d/%d local peers changed, %d connections changed", changedPeers, len(localPeers), changedConns)
+
+		// mostly the local peers connected to the removed one,
+		// a few more move on to other remote peers bounded by load
+		removed := connections(before, peers(remoteNames))["remote-0"]
+		assert.True(t, changedPeers >= removed && changedPeers <= removed+removed/4, "%d: %d", removed, changedPeers)
+		assert.Equal(t, 2*changedPeers, changedConns)
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		current, scaled := before, remoteNames
+		total := 0
+		for i := 100; i < 110; i++ {
+			scaled = append(scaled, fmt.Sprintf("remote-%d", i))
+			next := subsets(subset.NewRocksteady, localPeers, peers(scaled), 10)
+
+			changedPeers, _ := churn(current, next)
+			assert.True(t, changedPeers <= 60, "%d: %d", i, changedPeers)
+
+			total += changedPeers
+			current = next
+		}
+		t.Logf("scale from 100 to 110 remote peers: %d local peer changes in total", total)
+
+		// 27.3 connections for every remote peer on average, bounded by 1.25 times of it
+		for peer, conns := range connections(current, peers(scaled)) {
+			assert.True(t, conns >= 12 && conns <= 35, "%v: %d", peer, conns)
+		}
+	})
+}
+
+func TestRocksteadyOf(t *testing.T) {
+	type conn struct {
+		addr  string
+		state int
+	}
+
+	newSubsetter := func(remotePeers []*conn) []string {
+		s := subset.NewRocksteadyOf(func(c *conn) string { return c.addr })
+		s.SetSubsetSize(2)
+		s.SetLocalPeers([]string{"local-0", "local-1"})
+		s.SetRemotePeers(remotePeers)
+		s.SetLocalPeerID("local-0")
+
+		addrs := make([]string, 0)
+		for _, c := range s.Subset() {
+			addrs = append(addrs, c.addr)
+		}
+
+		return addrs
+	}
+
+	// remote peers are identified by key, other fields and pointers don't matter
+	remoteNames := names("remote", 5)
+	before := make([]*conn, 0)
+	after := make([]*conn, 0)
+	for i, name := range remoteNames {
+		before = append(before, &conn{addr: name})
+		after = append(after, &conn{addr: name, state: i})
+	}
+	assert.Equal(t, newSubsetter(before), newSubsetter(after))
+}
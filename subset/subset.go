@@ -0,0 +1,147 @@
+package subset
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/p2c"
+	"google.golang.org/grpc/balancer"
+)
+
+const (
+	// defaultSubsetSize is the number of remote peers for each local peer
+	defaultSubsetSize int = 12
+)
+
+// strategy returns the indices of remote peers selected for the local peer,
+// which is localPeers[localIdx]
+type strategy[T any] func(localPeers []string, localIdx int, remotePeers []T, size int) []int
+
+// subsetter support map local peers to fixed-size subsets of remote peers
+// by the strategy, and picks items in the subset by the picker
+type subsetter[T any] struct {
+	localID     string
+	localPeers  []string
+	remotePeers []T
+	subsetSize  int
+
+	strategy   strategy[T]
+	newPicker  loadbalance.PickerFactoryOf[T]
+	picker     atomic.Pointer[pickerHolder[T]]
+	subsetIdxs []int
+
+	mu sync.Mutex
+}
+
+func newSubsetter[T any](strategy strategy[T]) *subsetter[T] {
+	s := &subsetter[T]{
+		localPeers:  make([]string, 0),
+		remotePeers: make([]T, 0),
+		subsetSize:  defaultSubsetSize,
+		strategy:    strategy,
+		newPicker:   p2c.NewLeastLoadedOf[T],
+	}
+	s.picker.Store(&pickerHolder[T]{picker: s.newPicker()})
+
+	return s
+}
+
+// pickerHolder holds the picker swapped atomically,
+// pickers built by the factory may be of different types
+type pickerHolder[T any] struct {
+	picker loadbalance.PickerOf[T]
+}
+
+// SetSubsetSize sets the subset size
+func (s *subsetter[T]) SetSubsetSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size > 0 {
+		s.subsetSize = size
+		s.rebuild()
+	}
+}
+
+// SetLocalPeerID sets the local peer id
+func (s *subsetter[T]) SetLocalPeerID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.localID = id
+	s.rebuild()
+}
+
+// SetLocalPeers sets the local peers
+func (s *subsetter[T]) SetLocalPeers(localPeers []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.localPeers = localPeers
+	s.rebuild()
+}
+
+// SetRemotePeers sets the remote peers
+func (s *subsetter[T]) SetRemotePeers(remotePeers []T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remotePeers = remotePeers
+	s.rebuild()
+}
+
+// Subset returns the remote peers selected for the local peer
+func (s *subsetter[T]) Subset() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subset := make([]T, 0, len(s.subsetIdxs))
+	for _, idx := range s.subsetIdxs {
+		subset = append(subset, s.remotePeers[idx])
+	}
+
+	return subset
+}
+
+// Next returns the next selected item
+func (s *subsetter[T]) Next() (T, func(balancer.DoneInfo)) {
+	return s.picker.Load().picker.Next()
+}
+
+// Stats returns the state of every item in the subset
+func (s *subsetter[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	return loadbalance.Stats[T](s.picker.Load().picker)
+}
+
+// NextErr returns the next selected item, or an error if nothing is picked
+func (s *subsetter[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	return loadbalance.NextErr[T](s.picker.Load().picker)
+}
+
+// rebuild rebuilds the subset when any arguments changed,
+// the new picker is built and swapped in
+func (s *subsetter[T]) rebuild() {
+	localIdx := -1
+	for idx, local := range s.localPeers {
+		if local == s.localID {
+			localIdx = idx
+			break
+		}
+	}
+
+	if localIdx < 0 || len(s.remotePeers) == 0 {
+		s.subsetIdxs = nil
+		s.picker.Store(&pickerHolder[T]{picker: s.newPicker()})
+		return
+	}
+
+	s.subsetIdxs = s.strategy(s.localPeers, localIdx, s.remotePeers, s.subsetSize)
+
+	picker := s.newPicker()
+	for _, idx := range s.subsetIdxs {
+		picker.Add(s.remotePeers[idx], 1)
+	}
+	loadbalance.Inherit(picker, s.picker.Load().picker)
+	s.picker.Store(&pickerHolder[T]{picker: picker})
+}
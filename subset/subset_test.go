@@ -0,0 +1,143 @@
+package subset_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+func names(prefix string, n int) []string {
+	peers := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		peers = append(peers, fmt.Sprintf("%s-%d", prefix, i))
+	}
+
+	return peers
+}
+
+func peers(names []string) []interface{} {
+	peers := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		peers = append(peers, name)
+	}
+
+	return peers
+}
+
+// subsets returns the subset of every local peer
+func subsets(newSubsetter func() loadbalance.Subsetter, localPeers []string, remotePeers []interface{}, size int) [][]interface{} {
+	subsets := make([][]interface{}, 0, len(localPeers))
+	for _, local := range localPeers {
+		s := newSubsetter()
+		s.SetSubsetSize(size)
+		s.SetLocalPeers(localPeers)
+		s.SetRemotePeers(remotePeers)
+		s.SetLocalPeerID(local)
+		subsets = append(subsets, s.Subset())
+	}
+
+	return subsets
+}
+
+// connections returns the number of local peers connected to every remote peer
+func connections(subsets [][]interface{}, remotePeers []interface{}) map[interface{}]int {
+	conns := make(map[interface{}]int)
+	for _, peer := range remotePeers {
+		conns[peer] = 0
+	}
+
+	for _, subset := range subsets {
+		for _, peer := range subset {
+			conns[peer]++
+		}
+	}
+
+	return conns
+}
+
+// churn returns the number of local peers whose subset changed,
+// and the number of connections opened or closed
+func churn(before, after [][]interface{}) (int, int) {
+	changedPeers, changedConns := 0, 0
+	for i := range before {
+		old := make(map[interface{}]bool)
+		for _, peer := range before[i] {
+			old[peer] = true
+		}
+
+		changed := 0
+		for _, peer := range after[i] {
+			if old[peer] {
+				delete(old, peer)
+			} else {
+				changed++
+			}
+		}
+		changed += len(old)
+
+		if changed > 0 {
+			changedPeers++
+		}
+		changedConns += changed
+	}
+
+	return changedPeers, changedConns
+}
+
+func testSubsetter(t *testing.T, newSubsetter func() loadbalance.Subsetter) {
+	t.Run("0 item", func(t *testing.T) {
+		s := newSubsetter()
+		item, done := s.Next()
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+		assert.Empty(t, s.Subset())
+	})
+
+	t.Run("unknown local peer", func(t *testing.T) {
+		s := newSubsetter()
+		s.SetLocalPeers([]string{"1", "2"})
+		s.SetRemotePeers([]interface{}{"8", "9"})
+		s.SetLocalPeerID("3")
+
+		item, _ := s.Next()
+		assert.Nil(t, item)
+		assert.Empty(t, s.Subset())
+	})
+
+	t.Run("1 client 1 server", func(t *testing.T) {
+		s := newSubsetter()
+		s.SetLocalPeers([]string{"1"})
+		s.SetRemotePeers([]interface{}{"8"})
+		s.SetLocalPeerID("1")
+
+		item, done := s.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "8", item)
+		assert.Equal(t, []interface{}{"8"}, s.Subset())
+	})
+
+	t.Run("subset size", func(t *testing.T) {
+		s := newSubsetter()
+		s.SetLocalPeers([]string{"1", "2"})
+		s.SetRemotePeers(peers(names("remote", 10)))
+		s.SetLocalPeerID("1")
+		assert.Equal(t, 10, len(s.Subset()))
+
+		s.SetSubsetSize(3)
+		assert.Equal(t, 3, len(s.Subset()))
+
+		countMap := make(map[interface{}]int)
+		for i := 0; i < 300; i++ {
+			item, done := s.Next()
+			done(balancer.DoneInfo{})
+			countMap[item]++
+		}
+		assert.Equal(t, 3, len(countMap))
+		for _, peer := range s.Subset() {
+			assert.InDelta(t, 100, countMap[peer], 40)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+package zone
+
+// LocalPercent returns the percentage of traffic routed to the local zone
+func (z *ZoneOf[T]) LocalPercent() float64 {
+	return z.localPercent
+}
@@ -0,0 +1,220 @@
+package zone
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/internal"
+	"github.com/hnlq715/go-loadbalance/roundrobin"
+	"google.golang.org/grpc/balancer"
+)
+
+// residual is the share of the cross zone traffic routed to a zone
+type residual struct {
+	zone  string
+	share float64
+}
+
+// ZoneOf keeps traffic in the local zone as much as possible,
+// and routes the residual traffic to other zones by their spare capacity
+// when the local zone can't afford the local clients.
+// It's the same as envoy's zone aware routing.
+type ZoneOf[T any] struct {
+	info    loadbalance.SetInfo
+	clients map[string]float64
+
+	pickers  map[string]loadbalance.PickerOf[T]
+	capacity map[string]float64
+
+	localPercent float64
+	residuals    []residual
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Zone is a ZoneOf items of any type
+type Zone = ZoneOf[interface{}]
+
+// New returns a Zone picker, info.Zone is the local zone
+func New(info loadbalance.SetInfo) *Zone {
+	return NewOf[interface{}](info)
+}
+
+// NewOf returns a ZoneOf picker, info.Zone is the local zone
+func NewOf[T any](info loadbalance.SetInfo) *ZoneOf[T] {
+	return &ZoneOf[T]{
+		info:     info,
+		clients:  make(map[string]float64),
+		pickers:  make(map[string]loadbalance.PickerOf[T]),
+		capacity: make(map[string]float64),
+		rand:     rand.New(rand.NewSource(time.Now().Unix())),
+	}
+}
+
+var _ loadbalance.Set = (*Zone)(nil)
+
+// SetClientDistribution sets the number (or share) of clients in each zone,
+// without it all traffic stays in the local zone
+func (z *ZoneOf[T]) SetClientDistribution(clients map[string]float64) {
+	z.clients = make(map[string]float64, len(clients))
+	for zone, n := range clients {
+		if n > 0 {
+			z.clients[zone] = n
+		}
+	}
+
+	z.rebuild()
+}
+
+// Add a weighted item with set info, items belong to other sets are dropped
+func (z *ZoneOf[T]) Add(item T, weight float64, info loadbalance.SetInfo) {
+	if info.Name != z.info.Name || info.Region != z.info.Region {
+		return
+	}
+
+	picker, ok := z.pickers[info.Zone]
+	if !ok {
+		picker = roundrobin.NewSmoothRoundrobinOf[T]()
+		z.pickers[info.Zone] = picker
+	}
+
+	picker.Add(item, weight)
+	z.capacity[info.Zone] += weight
+
+	z.rebuild()
+}
+
+// Reset this picker
+func (z *ZoneOf[T]) Reset() {
+	z.pickers = make(map[string]loadbalance.PickerOf[T])
+	z.capacity = make(map[string]float64)
+
+	z.rebuild()
+}
+
+// Info returns the local set info
+func (z *ZoneOf[T]) Info() loadbalance.SetInfo {
+	return z.info
+}
+
+// Stats returns the state of every item of all zones
+func (z *ZoneOf[T]) Stats() []loadbalance.NodeStatsOf[T] {
+	zones := make([]string, 0, len(z.pickers))
+	for zone := range z.pickers {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	stats := make([]loadbalance.NodeStatsOf[T], 0)
+	for _, zone := range zones {
+		stats = append(stats, loadbalance.Stats[T](z.pickers[zone])...)
+	}
+
+	return stats
+}
+
+// Next returns the next selected item
+func (z *ZoneOf[T]) Next() (T, func(balancer.DoneInfo)) {
+	item, done, _ := z.NextErr()
+	return item, done
+}
+
+// NextErr returns the next selected item, or ErrNoAvailableItem if empty
+func (z *ZoneOf[T]) NextErr() (T, func(balancer.DoneInfo), error) {
+	// rand needs lock
+	z.mu.Lock()
+	r := z.rand.Float64()
+	z.mu.Unlock()
+
+	if r < z.localPercent {
+		return loadbalance.NextErr[T](z.pickers[z.info.Zone])
+	}
+
+	r = (r - z.localPercent) / (1 - z.localPercent)
+	for _, res := range z.residuals {
+		if r < res.share {
+			return loadbalance.NextErr[T](z.pickers[res.zone])
+		}
+		r -= res.share
+	}
+
+	// float rounding may leave a tiny tail, goes to the last zone
+	if len(z.residuals) > 0 {
+		return loadbalance.NextErr[T](z.pickers[z.residuals[len(z.residuals)-1].zone])
+	}
+
+	var zero T
+	return zero, internal.EmptyDoneFunc, loadbalance.ErrNoAvailableItem
+}
+
+// rebuild calculates the local percentage and residual shares
+// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/upstream/load_balancing/zone_aware
+func (z *ZoneOf[T]) rebuild() {
+	z.localPercent = 0
+	z.residuals = z.residuals[:0]
+
+	totalCapacity := float64(0)
+	for _, capacity := range z.capacity {
+		totalCapacity += capacity
+	}
+
+	totalClients := float64(0)
+	for _, n := range z.clients {
+		totalClients += n
+	}
+
+	if totalCapacity <= 0 {
+		return
+	}
+
+	localUpstream := z.capacity[z.info.Zone] / totalCapacity
+	switch {
+	case localUpstream <= 0:
+		// no local capacity at all, routes all traffic across zones
+	case totalClients <= 0:
+		// client distribution is unknown, keeps all traffic local
+		z.localPercent = 1
+		return
+	case localUpstream >= z.clients[z.info.Zone]/totalClients:
+		z.localPercent = 1
+		return
+	default:
+		z.localPercent = localUpstream / (z.clients[z.info.Zone] / totalClients)
+	}
+
+	// routes the residual traffic to zones with spare capacity,
+	// or by capacity if there's no spare capacity anywhere
+	zones := make([]string, 0, len(z.capacity))
+	for zone := range z.capacity {
+		if zone != z.info.Zone && z.capacity[zone] > 0 {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+
+	total := float64(0)
+	if totalClients > 0 {
+		for _, zone := range zones {
+			spare := z.capacity[zone]/totalCapacity - z.clients[zone]/totalClients
+			if spare > 0 {
+				z.residuals = append(z.residuals, residual{zone: zone, share: spare})
+				total += spare
+			}
+		}
+	}
+
+	if len(z.residuals) == 0 {
+		for _, zone := range zones {
+			z.residuals = append(z.residuals, residual{zone: zone, share: z.capacity[zone]})
+			total += z.capacity[zone]
+		}
+	}
+
+	for i := range z.residuals {
+		z.residuals[i].share /= total
+	}
+}
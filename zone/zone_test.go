@@ -0,0 +1,178 @@
+package zone_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hnlq715/go-loadbalance"
+	"github.com/hnlq715/go-loadbalance/zone"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+)
+
+func info(z string) loadbalance.SetInfo {
+	return loadbalance.SetInfo{Name: "app", Region: "bj", Zone: z}
+}
+
+// simulate sends requests from every zone proportional to the clients,
+// and returns the requests received by each upstream node
+func simulate(upstreams, clients map[string]int, totalCount int) map[interface{}]int {
+	totalClients := 0
+	distribution := make(map[string]float64)
+	for z, n := range clients {
+		totalClients += n
+		distribution[z] = float64(n)
+	}
+
+	countMap := make(map[interface{}]int)
+	for z, n := range clients {
+		p := zone.New(info(z))
+		p.SetClientDistribution(distribution)
+		for uz, un := range upstreams {
+			for i := 0; i < un; i++ {
+				p.Add(fmt.Sprintf("%s-%d", uz, i), 1, info(uz))
+			}
+		}
+
+		for i := 0; i < totalCount*n/totalClients; i++ {
+			item, done := p.Next()
+			done(balancer.DoneInfo{})
+			countMap[item]++
+		}
+	}
+
+	return countMap
+}
+
+func TestZone(t *testing.T) {
+	t.Run("0 item", func(t *testing.T) {
+		p := zone.New(info("a"))
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+	})
+
+	t.Run("different set", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.Add(1, 1, loadbalance.SetInfo{Name: "app", Region: "sh", Zone: "a"})
+		p.Add(2, 1, loadbalance.SetInfo{Name: "other", Region: "bj", Zone: "a"})
+
+		item, _ := p.Next()
+		assert.Nil(t, item)
+	})
+
+	t.Run("unknown distribution", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.Add(1, 1, info("a"))
+		p.Add(2, 5, info("b"))
+
+		assert.Equal(t, float64(1), p.LocalPercent())
+		for i := 0; i < 100; i++ {
+			item, _ := p.Next()
+			assert.Equal(t, 1, item)
+		}
+	})
+
+	t.Run("no local upstream", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.SetClientDistribution(map[string]float64{"a": 1, "b": 1})
+		p.Add(2, 1, info("b"))
+
+		assert.Equal(t, float64(0), p.LocalPercent())
+		item, _ := p.Next()
+		assert.Equal(t, 2, item)
+	})
+
+	t.Run("enough local capacity", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.SetClientDistribution(map[string]float64{"a": 1, "b": 1})
+		p.Add(1, 1, info("a"))
+		p.Add(2, 1, info("b"))
+
+		assert.Equal(t, float64(1), p.LocalPercent())
+	})
+
+	t.Run("residual", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.SetClientDistribution(map[string]float64{"a": 1, "b": 1, "c": 1})
+		p.Add(1, 1, info("a"))
+		p.Add(2, 3, info("b"))
+		p.Add(3, 2, info("c"))
+
+		// upstream a is 1/6 and clients a is 1/3
+		assert.InDelta(t, 0.5, p.LocalPercent(), 0.001)
+
+		countMap := make(map[interface{}]int)
+		totalCount := 10000
+		for i := 0; i < totalCount; i++ {
+			item, _ := p.Next()
+			countMap[item]++
+		}
+
+		// only zone b has spare capacity
+		assert.InDelta(t, totalCount/2, countMap[1], 300)
+		assert.InDelta(t, totalCount/2, countMap[2], 300)
+		assert.Zero(t, countMap[3])
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		p := zone.New(info("a"))
+		p.Add(1, 1, info("a"))
+		p.Reset()
+
+		item, _ := p.Next()
+		assert.Nil(t, item)
+	})
+}
+
+func TestZoneSimulation(t *testing.T) {
+	cases := []struct {
+		name      string
+		upstreams map[string]int
+		clients   map[string]int
+	}{
+		{
+			name:      "balanced",
+			upstreams: map[string]int{"a": 2, "b": 2, "c": 2},
+			clients:   map[string]int{"a": 10, "b": 10, "c": 10},
+		},
+		{
+			name:      "skewed upstreams",
+			upstreams: map[string]int{"a": 1, "b": 3, "c": 2},
+			clients:   map[string]int{"a": 10, "b": 10, "c": 10},
+		},
+		{
+			name:      "skewed clients",
+			upstreams: map[string]int{"a": 2, "b": 2, "c": 2},
+			clients:   map[string]int{"a": 30, "b": 20, "c": 10},
+		},
+		{
+			name:      "skewed both",
+			upstreams: map[string]int{"a": 1, "b": 4, "c": 5},
+			clients:   map[string]int{"a": 40, "b": 40, "c": 20},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			totalCount := 60000
+			countMap := simulate(c.upstreams, c.clients, totalCount)
+
+			nodes := 0
+			for _, n := range c.upstreams {
+				nodes += n
+			}
+
+			// every node should get the same share of requests
+			total := 0
+			for item, count := range countMap {
+				total += count
+				assert.InDelta(t, totalCount/nodes, count, float64(totalCount/nodes)/10, item)
+			}
+
+			assert.Equal(t, nodes, len(countMap))
+			assert.Equal(t, totalCount, total)
+		})
+	}
+}